@@ -1,20 +1,77 @@
 package db
 
 import (
+	"context"
 	"errors"
+	"reflect"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type Map = map[string]any
 
+// Expr is an SQL expression with optional args.
+type Expr = clause.Expr
+
+// E returns an SQL expression with optional args, for use with UpdateExpr.
+//
+// Example:
+//
+//	db.E("price * ?", 1.2)
+func E(sql string, args ...any) Expr {
+	return gorm.Expr(sql, args...)
+}
+
+var ErrNoRowsAffected = errors.New("no rows affected")
+
 type Builder[T any] struct {
-	query *gorm.DB
+	query       *gorm.DB
+	mustAffect  bool
+	onPrimary   bool
+	onlyTrashed bool
+}
+
+// Subquery is implemented by *Builder, allowing builders to be used as subqueries in the args of
+// Where, OrWhere and WhereNot, the values of WhereIn and WhereNotIn, and the source of From.
+//
+// Example:
+//
+//	db.B[User]().Where("id IN (?)", db.B[Order]("total > ?", 100).Select("user_id"))
+type Subquery interface {
+	subquery() *gorm.DB
+}
+
+// resolveArgs returns args with any subqueries replaced by their underlying queries, which gorm
+// builds into the SQL of the outer query.
+func resolveArgs(args []any) []any {
+	resolved := make([]any, len(args))
+
+	for n, arg := range args {
+		if subquery, ok := arg.(Subquery); ok {
+			resolved[n] = subquery.subquery()
+		} else {
+			resolved[n] = arg
+		}
+	}
+
+	return resolved
+}
+
+// Result is the result of an Update, Delete or HardDelete.
+//
+// Rows count as affected if they matched the query, even if an update didn't need to change them,
+// only when the DSN sets clientFoundRows, as the one built by Config does. Otherwise (such as with a
+// *gorm.DB passed to SetInstance that was opened without it) MySQL only counts rows that changed.
+type Result struct {
+	RowsAffected int64 // The number of rows affected.
 }
 
 // B returns a new *Builder prepared for model T.
 //
 // The builder can be initialised with a where query by passing in a string followed by (optional) args.
+// Any default scopes registered for T with DefaultScope are included.
 //
 // Examples:
 //
@@ -22,11 +79,11 @@ type Builder[T any] struct {
 //	db.B[Model]("id = ?", 1)
 //	db.B[Model]("id = ? and name = ?", 1, "John")
 func B[T any](args ...any) *Builder[T] {
-	query := i.Model(new(T))
+	query := applyDefaultScopes[T](i.Model(new(T)))
 
 	if len(args) > 0 {
 		if s, ok := args[0].(string); ok {
-			query = query.Where(s, args[1:]...)
+			query = query.Where(s, resolveArgs(args[1:])...)
 		} else {
 			panic("invalid parameter")
 		}
@@ -44,17 +101,30 @@ func B[T any](args ...any) *Builder[T] {
 // Debug ensures queries from this builder always log to the terminal. This method does not modify
 // the current builder.
 func (self *Builder[T]) Debug() *Builder[T] {
-	return &Builder[T]{
-		query: self.query.Debug(),
-	}
+	return self.with(self.query.Debug())
 }
 
 // Unscoped ensures queries include soft-deleted rows. This method does not modify the current
 // builder.
 func (self *Builder[T]) Unscoped() *Builder[T] {
-	return &Builder[T]{
-		query: self.query.Unscoped(),
-	}
+	return self.with(self.clone().Unscoped())
+}
+
+// Tx ensures queries from this builder run within the transaction tx (see Transaction). This method
+// does not modify the current builder.
+func (self *Builder[T]) Tx(tx *gorm.DB) *Builder[T] {
+	query := self.clone()
+	query.Statement.ConnPool = tx.Statement.ConnPool
+	return self.with(query)
+}
+
+// MustAffect ensures that Update, UpdateExpr, Increment, Decrement, Delete and HardDelete call the
+// error handler with ErrNoRowsAffected if no rows were affected (see Result). This method does not
+// modify the current builder.
+func (self *Builder[T]) MustAffect() *Builder[T] {
+	builder := self.with(self.clone())
+	builder.mustAffect = true
+	return builder
 }
 
 // Where adds a WHERE clause to the query.
@@ -64,7 +134,128 @@ func (self *Builder[T]) Unscoped() *Builder[T] {
 //	Where("id = ?", 1)
 //	Where("id = ? and name = ?", 1, "John")
 func (self *Builder[T]) Where(query string, args ...any) *Builder[T] {
-	self.query = self.query.Where(query, args...)
+	self.query = self.query.Where(query, resolveArgs(args)...)
+	return self
+}
+
+// OrWhere adds a condition to the query that is joined to the previous conditions with OR.
+//
+// Examples:
+//
+//	Where("id = ?", 1).OrWhere("id = ?", 2)
+func (self *Builder[T]) OrWhere(query string, args ...any) *Builder[T] {
+	self.query = self.query.Or(query, resolveArgs(args)...)
+	return self
+}
+
+// WhereIn adds a WHERE column IN (...) clause to the query. The values parameter can be a slice or
+// a subquery. If values is an empty slice then no rows match.
+//
+// Examples:
+//
+//	WhereIn("id", []int{1, 2, 3})
+//	WhereIn("id", db.B[Order]("total > ?", 100).Select("user_id"))
+func (self *Builder[T]) WhereIn(column string, values any) *Builder[T] {
+	if subquery, ok := values.(Subquery); ok {
+		self.query = self.query.Where("? IN (?)", clause.Column{Name: column}, subquery.subquery())
+		return self
+	}
+
+	self.query = self.query.Where(clause.IN{Column: clause.Column{Name: column}, Values: toSlice(values)})
+	return self
+}
+
+// WhereNotIn adds a WHERE column NOT IN (...) clause to the query. The values parameter can be a
+// slice or a subquery. If values is an empty slice then the clause has no effect.
+//
+// Examples:
+//
+//	WhereNotIn("id", []int{1, 2, 3})
+//	WhereNotIn("id", db.B[Order]("total > ?", 100).Select("user_id"))
+func (self *Builder[T]) WhereNotIn(column string, values any) *Builder[T] {
+	if subquery, ok := values.(Subquery); ok {
+		self.query = self.query.Where("? NOT IN (?)", clause.Column{Name: column}, subquery.subquery())
+		return self
+	}
+
+	if values := toSlice(values); len(values) > 0 {
+		self.query = self.query.Not(clause.IN{Column: clause.Column{Name: column}, Values: values})
+	}
+	return self
+}
+
+// WhereNot adds a negated WHERE clause to the query.
+//
+// Examples:
+//
+//	WhereNot("id = ?", 1)
+func (self *Builder[T]) WhereNot(query string, args ...any) *Builder[T] {
+	self.query = self.query.Not(query, resolveArgs(args)...)
+	return self
+}
+
+// WhereNull adds a WHERE column IS NULL clause to the query.
+func (self *Builder[T]) WhereNull(column string) *Builder[T] {
+	self.query = self.query.Where(clause.Expr{SQL: "? IS NULL", Vars: []any{clause.Column{Name: column}}})
+	return self
+}
+
+// WhereNotNull adds a WHERE column IS NOT NULL clause to the query.
+func (self *Builder[T]) WhereNotNull(column string) *Builder[T] {
+	self.query = self.query.Where(clause.Expr{SQL: "? IS NOT NULL", Vars: []any{clause.Column{Name: column}}})
+	return self
+}
+
+// WhereBetween adds a WHERE column BETWEEN low AND high clause to the query.
+func (self *Builder[T]) WhereBetween(column string, low any, high any) *Builder[T] {
+	self.query = self.query.Where(clause.Expr{
+		SQL:  "? BETWEEN ? AND ?",
+		Vars: []any{clause.Column{Name: column}, low, high},
+	})
+	return self
+}
+
+// WhereGroup adds the conditions added by f to the query as a single parenthesised WHERE clause.
+//
+// Examples:
+//
+//	WhereGroup(func(b *db.Builder[Model]) {
+//		b.Where("a = ?", 1).OrWhere("b = ?", 2)
+//	})
+func (self *Builder[T]) WhereGroup(f func(*Builder[T])) *Builder[T] {
+	self.query = self.query.Where(self.group(f))
+	return self
+}
+
+// OrWhereGroup adds the conditions added by f to the query as a single parenthesised clause that is
+// joined to the previous conditions with OR.
+func (self *Builder[T]) OrWhereGroup(f func(*Builder[T])) *Builder[T] {
+	self.query = self.query.Or(self.group(f))
+	return self
+}
+
+// Select sets the columns to select, which is mostly useful for subqueries.
+//
+// Examples:
+//
+//	Select("id")
+//	Select("user_id", "total")
+func (self *Builder[T]) Select(columns ...string) *Builder[T] {
+	self.query = self.query.Select(columns)
+	return self
+}
+
+// From sets the source of the query to a subquery, which is given the name alias. Soft-deleted rows
+// and default scopes are left for the subquery to exclude, as the outer query can't refer to the
+// model's table.
+//
+// Examples:
+//
+//	From(db.B[Model]().Select("id", "name").Where("n > ?", 1), "m")
+func (self *Builder[T]) From(subquery Subquery, alias string) *Builder[T] {
+	self.query = self.query.Table("(?) AS ?", subquery.subquery(), clause.Table{Name: alias}).
+		Unscoped().
+		Set(withoutScopesKey, map[string]bool{"*": true})
 	return self
 }
 
@@ -104,36 +295,47 @@ func (self *Builder[T]) Distinct() *Builder[T] {
 // Find returns all rows that match the query.
 func (self *Builder[T]) Find() []*T {
 	var rows []*T
-	must0(self.query.Find(&rows).Error)
+	must0(retry(self.query, func() error {
+		rows = nil
+		return self.read().Find(&rows).Error
+	}))
 	return rows
 }
 
 // First returns the first row that matches the query, and true if it was able to find a row.
 func (self *Builder[T]) First() (*T, bool) {
 	var row *T
-	res := self.query.First(&row)
-	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+	err := retry(self.query, func() error {
+		row = nil
+		return self.read().First(&row).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return row, false
 	}
-	must0(res.Error)
+	must0(err)
 	return row, true
 }
 
 // Last returns the last row that matches the query, and true if it was able to find a row.
 func (self *Builder[T]) Last() (*T, bool) {
 	var row *T
-	res := self.query.Last(&row)
-	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+	err := retry(self.query, func() error {
+		row = nil
+		return self.read().Last(&row).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return row, false
 	}
-	must0(res.Error)
+	must0(err)
 	return row, true
 }
 
 // Count returns the number of rows that match the query.
 func (self *Builder[T]) Count() int64 {
 	var n int64
-	must0(self.query.Count(&n).Error)
+	must0(retry(self.query, func() error {
+		return self.read().Count(&n).Error
+	}))
 	return n
 }
 
@@ -142,13 +344,83 @@ func (self *Builder[T]) Exists() bool {
 	return self.Count() > 0
 }
 
-// Update updates the value(s) of column(s) for the rows that match the query. The values
-// parameter can take many forms.
+// FindByIDs returns the rows with the specified primary keys that match the query, in the same
+// order as ids. IDs that aren't found are skipped.
+func (self *Builder[T]) FindByIDs(ids any) []*T {
+	rows, _ := self.findByIDs(toSlice(ids))
+	return rows
+}
+
+// FindByIDsStrict is like FindByIDs, but if any ids aren't found then the error handler is called
+// with a *MissingIDsError, and the missing ids are returned.
+func (self *Builder[T]) FindByIDsStrict(ids any) ([]*T, []any) {
+	rows, missing := self.findByIDs(toSlice(ids))
+	if len(missing) > 0 {
+		must0(&MissingIDsError{IDs: missing})
+	}
+	return rows, missing
+}
+
+func (self *Builder[T]) findByIDs(ids []any) ([]*T, []any) {
+	if len(ids) == 0 {
+		return []*T{}, nil
+	}
+
+	query := self.read()
+	must0(query.Statement.Parse(new(T)))
+
+	primaryKey := query.Statement.Schema.PrioritizedPrimaryField
+	if primaryKey == nil {
+		panic("model has no primary key")
+	}
+
+	// Group any ORed conditions so that the condition on ids applies to all of them.
+	groupWhere(query.Statement)
+
+	condition := clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: primaryKey.DBName}, Values: ids}
+
+	var rows []*T
+	must0(retry(query, func() error {
+		rows = nil
+		return query.Session(&gorm.Session{Context: query.Statement.Context}).Where(condition).Find(&rows).Error
+	}))
+
+	return orderByIDs(query.Statement.Context, primaryKey, ids, rows)
+}
+
+// orderByIDs returns the row from rows with each of ids as its primaryKey, skipping ids that have
+// no row, and the ids that were skipped.
+func orderByIDs[T any](ctx context.Context, primaryKey *schema.Field, ids []any, rows []*T) ([]*T, []any) {
+	index := newRowIndex(rows, func(row *T) []any {
+		id, _ := primaryKey.ValueOf(ctx, reflect.ValueOf(row).Elem())
+		return []any{id}
+	})
+
+	var missing []any
+	ordered := make([]*T, 0, len(ids))
+	for _, id := range ids {
+		if row, ok := index.find(id); ok {
+			ordered = append(ordered, row)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return ordered, missing
+}
+
+// Update updates the value(s) of column(s) for the rows that match the query, and returns the
+// number of rows affected, which includes rows that already had the new values if the DSN sets
+// clientFoundRows (see Result). The values parameter can take many forms.
 //
 // Sequence of key/value pairs (like in slog):
 //
 //	Update("foo", "bar", "n", 123)
 //
+// Keys may also be Fields:
+//
+//	Update(Cols.Model.Foo, "bar", Cols.Model.N, 123)
+//
 // Slice of sequence of key/value pairs:
 //
 //	updates := []any{"foo", "bar", "n", 123}
@@ -162,42 +434,155 @@ func (self *Builder[T]) Exists() bool {
 // Model (only updates non-zero fields):
 //
 //	Update(m.Model{Foo: "bar", N: 123})
-func (self *Builder[T]) Update(values ...any) {
-	f := func(values []any) {
+func (self *Builder[T]) Update(values ...any) Result {
+	f := func(values []any) *gorm.DB {
 		if (len(values) % 2) != 0 {
 			panic("values argument must be an even number of elements (or 1)")
 		}
 
 		m := map[string]any{}
 		for i := 0; i < len(values); i += 2 {
-			m[values[i].(string)] = values[i+1]
+			m[columnName(values[i])] = values[i+1]
 		}
 
-		must0(self.query.Updates(m).Error)
+		return self.query.Updates(m)
 	}
 
+	var res *gorm.DB
+
 	switch len(values) {
 	case 0:
-		return
+		return Result{}
 	case 1:
 		values := values[0]
 
 		if slice, ok := values.([]any); ok {
-			f(slice)
+			res = f(slice)
 		} else {
-			must0(self.query.Updates(values).Error)
+			res = self.query.Updates(values)
 		}
 	default:
-		f(values)
+		res = f(values)
+	}
+
+	must0(res.Error)
+	self.checkAffected(res.RowsAffected > 0)
+
+	return Result{RowsAffected: res.RowsAffected}
+}
+
+// Increment atomically adds n to column for the rows that match the query, and returns the number
+// of rows affected.
+func (self *Builder[T]) Increment(column string, n any) int64 {
+	return self.UpdateExpr(map[string]Expr{
+		column: gorm.Expr("? + ?", clause.Column{Name: column}, n),
+	})
+}
+
+// Decrement atomically subtracts n from column for the rows that match the query, and returns the
+// number of rows affected.
+func (self *Builder[T]) Decrement(column string, n any) int64 {
+	return self.UpdateExpr(map[string]Expr{
+		column: gorm.Expr("? - ?", clause.Column{Name: column}, n),
+	})
+}
+
+// UpdateExpr updates columns to the result of SQL expressions for the rows that match the query,
+// and returns the number of rows affected. Because the expressions are evaluated by the database
+// they can safely refer to the current values of columns.
+//
+// Example:
+//
+//	UpdateExpr(map[string]db.Expr{
+//		"views": db.E("views + ?", 1),
+//		"score": db.E("score * ?", 2),
+//	})
+func (self *Builder[T]) UpdateExpr(values map[string]Expr) int64 {
+	m := make(map[string]any, len(values))
+	for column, expr := range values {
+		m[column] = expr
+	}
+
+	res := self.query.Updates(m)
+	must0(res.Error)
+	self.checkAffected(res.RowsAffected > 0)
+	return res.RowsAffected
+}
+
+// Delete soft-deletes all rows that match the query, along with the rows of any cascades declared
+// with Cascade, and returns the number of rows deleted. It panics if the builder has OnlyTrashed.
+func (self *Builder[T]) Delete() Result {
+	if self.onlyTrashed {
+		// The query is unscoped, so this would permanently delete the trashed rows.
+		panic("Delete can't be used with OnlyTrashed (use HardDelete to permanently delete trashed rows)")
+	}
+
+	return self.delete(softDelete[T](self.query))
+}
+
+// HardDelete hard-deletes all rows that match the query, and returns the number of rows deleted.
+func (self *Builder[T]) HardDelete() Result {
+	res := self.query.Unscoped().Delete(new(T))
+	return self.delete(res.RowsAffected, res.Error)
+}
+
+func (self *Builder[T]) delete(n int64, err error) Result {
+	must0(err)
+	self.checkAffected(n > 0)
+
+	return Result{RowsAffected: n}
+}
+
+// ToSQL returns the SQL and args of the query that finisher would run, without running it. If
+// finisher runs more than one query, the first one is returned. Finishers that stream rows (Each
+// and Rows) aren't supported and panic.
+//
+// Example:
+//
+//	sql, args := db.B[Model]("id = ?", 1).ToSQL(func(b *db.Builder[Model]) {
+//		b.Find()
+//	})
+func (self *Builder[T]) ToSQL(finisher func(*Builder[T])) (string, []any) {
+	recorder := &sqlRecorder{}
+
+	// Nothing is affected in dry run mode, so MustAffect would always fail.
+	builder := self.with(self.query.Session(&gorm.Session{DryRun: true}).Set(sqlRecorderKey, recorder))
+	builder.mustAffect = false
+
+	finisher(builder)
+	return recorder.sql, recorder.args
+}
+
+// checkAffected calls the error handler with ErrNoRowsAffected if the builder was created with
+// MustAffect and ok is false.
+func (self *Builder[T]) checkAffected(ok bool) {
+	if self.mustAffect && !ok {
+		must0(ErrNoRowsAffected)
 	}
 }
 
-// Delete soft-deletes all rows that match the query.
-func (self *Builder[T]) Delete() {
-	must0(self.query.Delete(new(T)).Error)
+// subquery returns the query for use as a subquery of another query.
+func (self *Builder[T]) subquery() *gorm.DB {
+	return self.clone()
 }
 
-// HardDelete hard-deletes all rows that match the query.
-func (self *Builder[T]) HardDelete() {
-	must0(self.query.Unscoped().Delete(new(T)).Error)
+// group returns a query containing only the conditions added by f.
+func (self *Builder[T]) group(f func(*Builder[T])) *gorm.DB {
+	group := self.with(self.query.Session(&gorm.Session{NewDB: true}))
+	f(group)
+	return group.query
+}
+
+// with returns a copy of the current builder that uses query.
+func (self *Builder[T]) with(query *gorm.DB) *Builder[T] {
+	builder := *self
+	builder.query = query
+	return &builder
+}
+
+// clone returns a copy of the underlying query that can be modified and executed without affecting
+// the current builder.
+func (self *Builder[T]) clone() *gorm.DB {
+	// Passing a context gives the session its own copy of the statement.
+	return self.query.Session(&gorm.Session{Context: self.query.Statement.Context})
 }
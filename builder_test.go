@@ -0,0 +1,433 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gorm_mysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type builderModel struct {
+	ID   uint
+	Name string
+	Age  int
+}
+
+// dryRun sets the instance to one that builds queries without running them.
+
+func dryRun(t *testing.T) {
+	dialector := gorm_mysql.New(gorm_mysql.Config{
+		DSN:                       "user@tcp(localhost)/test",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	assert.Nil(t, err)
+
+	SetInstance(db)
+}
+
+// findSQL returns the SQL that Find would run for builder, with the args interpolated.
+
+func findSQL[T any](builder *Builder[T]) string {
+	var rows []*T
+	stmt := builder.query.Find(&rows).Statement
+	return i.Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+}
+
+func TestWhere(t *testing.T) {
+	dryRun(t)
+
+	testCases := []struct {
+		builder       *Builder[builderModel]
+		expectedWhere string
+	}{
+		{
+			builder:       B[builderModel]().WhereIn("id", []int{1, 2}),
+			expectedWhere: "`id` IN (1,2)",
+		},
+		{
+			builder:       B[builderModel]().WhereIn("id", []int{}),
+			expectedWhere: "`id` IN (NULL)",
+		},
+		{
+			builder:       B[builderModel]().WhereNotIn("id", []int{1, 2}),
+			expectedWhere: "`id` NOT IN (1,2)",
+		},
+		{
+			builder:       B[builderModel]().Where("age > ?", 18).WhereNotIn("id", []int{}),
+			expectedWhere: "age > 18",
+		},
+		{
+			builder:       B[builderModel]().Where("id = ?", 1).OrWhere("id = ?", 2),
+			expectedWhere: "id = 1 OR id = 2",
+		},
+		{
+			builder:       B[builderModel]().WhereNot("id = ?", 1),
+			expectedWhere: "NOT id = 1",
+		},
+		{
+			builder:       B[builderModel]().WhereNull("name").WhereNotNull("age"),
+			expectedWhere: "`name` IS NULL AND `age` IS NOT NULL",
+		},
+		{
+			builder:       B[builderModel]().WhereBetween("age", 18, 30),
+			expectedWhere: "`age` BETWEEN 18 AND 30",
+		},
+		{
+			builder: B[builderModel]("age > ?", 18).WhereGroup(func(b *Builder[builderModel]) {
+				b.Where("name = ?", "John").OrWhere("name = ?", "Jane")
+			}),
+			expectedWhere: "age > 18 AND (name = 'John' OR name = 'Jane')",
+		},
+		{
+			builder: B[builderModel]("age > ?", 18).OrWhereGroup(func(b *Builder[builderModel]) {
+				b.Where("name = ?", "John").Where("age < ?", 10)
+			}),
+			expectedWhere: "age > 18 OR (name = 'John' AND age < 10)",
+		},
+		{
+			builder: B[builderModel]().Filter(
+				NewField[builderModel, int]("age").Gte(18),
+				NewField[builderModel, string]("name").In("John", "Jane"),
+			),
+			expectedWhere: "`age` >= 18 AND `name` IN ('John','Jane')",
+		},
+		{
+			builder: B[builderModel]().Filter(
+				NewField[builderModel, uint]("id").Eq(1).Or(NewField[builderModel, string]("name").IsNull()),
+				NewField[builderModel, int]("age").Between(18, 30).Not(),
+			),
+			expectedWhere: "(`id` = 1 OR `name` IS NULL) AND NOT (`age` BETWEEN 18 AND 30)",
+		},
+		{
+			builder:       B[builderModel]("age > ?", 18).WhereIn("id", B[builderModel]("name = ?", "John").Select("id")),
+			expectedWhere: "age > 18 AND `id` IN (SELECT `id` FROM `builder_models` WHERE name = 'John')",
+		},
+		{
+			builder:       B[builderModel]().WhereNotIn("id", B[builderModel]("name = ?", "John").Select("id")),
+			expectedWhere: "`id` NOT IN (SELECT `id` FROM `builder_models` WHERE name = 'John')",
+		},
+		{
+			builder:       B[builderModel]("age > ? AND id IN (?) AND age < ?", 18, B[builderModel]("name = ?", "John").Select("id"), 30),
+			expectedWhere: "age > 18 AND id IN (SELECT `id` FROM `builder_models` WHERE name = 'John') AND age < 30",
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("Case%d", i+1), func(t *testing.T) {
+			expected := "SELECT * FROM `builder_models` WHERE " + testCase.expectedWhere
+			assert.Equal(t, expected, findSQL(testCase.builder))
+		})
+	}
+}
+
+type upsertModel struct {
+	ID    uint
+	Email string
+	Name  string
+	Age   int
+}
+
+func TestUpsertInBatches(t *testing.T) {
+	dryRun(t)
+
+	var statements []string
+	record := func(db *gorm.DB) {
+		statements = append(statements, i.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	}
+	assert.Nil(t, i.Callback().Create().After("*").Register("test:record", record))
+	assert.Nil(t, i.Callback().Query().After("*").Register("test:record", record))
+
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+
+	rows := []*upsertModel{
+		{Email: "a", Name: "A", Age: 1},
+		{Email: "b", Name: "B", Age: 2},
+		{Email: "c", Name: "C", Age: 3},
+	}
+
+	UpsertInBatches(rows, []string{"Email"}, []string{"Name", "age"}, 2)
+
+	assert.Equal(t, []string{
+		"INSERT INTO `upsert_models` (`email`,`name`,`age`) VALUES ('a','A',1),('b','B',2) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`age`=VALUES(`age`)",
+		"INSERT INTO `upsert_models` (`email`,`name`,`age`) VALUES ('c','C',3) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`age`=VALUES(`age`)",
+		"SELECT * FROM `upsert_models` WHERE (`email` = 'a' OR `email` = 'b')",
+		"SELECT * FROM `upsert_models` WHERE `email` = 'c'",
+	}, statements)
+
+	// Nothing is found in dry run mode.
+	assert.EqualError(t, handled, "3 of 3 upserted rows not found when reloading")
+
+	assert.Panics(t, func() { Upsert(&upsertModel{}, nil, nil) })
+}
+
+func TestUpdateExpr(t *testing.T) {
+	dryRun(t)
+
+	var statements []string
+	assert.Nil(t, i.Callback().Update().After("*").Register("test:record", func(db *gorm.DB) {
+		statements = append(statements, i.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	}))
+
+	B[builderModel]("id = ?", 1).Increment("age", 1)
+	B[builderModel]("id = ?", 1).Decrement("age", 2)
+	B[builderModel]("id = ?", 1).UpdateExpr(map[string]Expr{
+		"name": E("CONCAT(name, ?)", "!"),
+		"age":  E("age * ?", 2),
+	})
+
+	assert.Equal(t, []string{
+		"UPDATE `builder_models` SET `age`=`age` + 1 WHERE id = 1",
+		"UPDATE `builder_models` SET `age`=`age` - 2 WHERE id = 1",
+		"UPDATE `builder_models` SET `age`=age * 2,`name`=CONCAT(name, '!') WHERE id = 1",
+	}, statements)
+}
+
+type scopedModel struct {
+	ID      uint
+	Hidden  bool
+	Deleted bool
+}
+
+func TestScopes(t *testing.T) {
+	dryRun(t)
+
+	DefaultScope("visible", func(b *Builder[scopedModel]) *Builder[scopedModel] {
+		return b.Where("hidden = ?", false)
+	})
+
+	DefaultScope("active", func(b *Builder[scopedModel]) *Builder[scopedModel] {
+		return b.Where("deleted = ?", false)
+	})
+
+	byID := func(b *Builder[scopedModel]) *Builder[scopedModel] {
+		return b.Where("id = ?", 1)
+	}
+
+	const query = "SELECT * FROM `scoped_models`"
+
+	assert.Equal(t, query+" WHERE hidden = false AND deleted = false", findSQL(B[scopedModel]()))
+	assert.Equal(t, query+" WHERE id = 1 AND hidden = false AND deleted = false", findSQL(B[scopedModel]().Scopes(byID)))
+	assert.Equal(t, query+" WHERE id = 1 AND deleted = false", findSQL(B[scopedModel]().Scopes(byID).WithoutScopes("visible")))
+	assert.Equal(t, query+" WHERE id = 1", findSQL(B[scopedModel]().Scopes(byID).WithoutScopes()))
+	assert.Equal(t, query, findSQL(B[scopedModel]().WithoutScopes("visible").WithoutScopes("active")))
+
+	// Conditions ORed on the builder mustn't escape the default scopes.
+	assert.Equal(t, query+" WHERE (id = 1 OR id = 2) AND hidden = false AND deleted = false", findSQL(B[scopedModel]("id = ?", 1).OrWhere("id = ?", 2)))
+	assert.Equal(t, query+" WHERE (id = 1 OR (id = 2 AND hidden = true)) AND deleted = false", findSQL(B[scopedModel]("id = ?", 1).OrWhereGroup(func(b *Builder[scopedModel]) {
+		b.Where("id = ?", 2).Where("hidden = ?", true)
+	}).WithoutScopes("visible")))
+}
+
+type fromScopedModel struct {
+	ID     uint
+	Hidden bool
+}
+
+func TestFrom(t *testing.T) {
+	dryRun(t)
+
+	subquery := B[builderModel]("age > ?", 18).Select("name", "age")
+	builder := B[builderModel]().From(subquery, "adults").Where("name = ?", "John")
+
+	expected := "SELECT * FROM (SELECT `name`,`age` FROM `builder_models` WHERE age > 18) AS `adults` WHERE name = 'John'"
+	assert.Equal(t, expected, findSQL(builder))
+
+	// Soft deletes are only excluded by the subquery, which is the only place the table is in scope.
+	parents := B[sdParent]().From(B[sdParent]().Select("id"), "p")
+	expected = "SELECT * FROM (SELECT `id` FROM `sd_parents` WHERE `sd_parents`.`deleted_at` IS NULL) AS `p`"
+	assert.Equal(t, expected, findSQL(parents))
+	// Likewise for default scopes.
+	DefaultScope("visible", func(b *Builder[fromScopedModel]) *Builder[fromScopedModel] {
+		return b.Where("hidden = ?", false)
+	})
+
+	scoped := B[fromScopedModel]().From(B[fromScopedModel]().Select("id"), "s")
+	expected = "SELECT * FROM (SELECT `id` FROM `from_scoped_models` WHERE hidden = false) AS `s`"
+	assert.Equal(t, expected, findSQL(scoped))
+}
+
+func TestToSQL(t *testing.T) {
+	dryRun(t)
+
+	sql, args := B[builderModel]("age > ?", 18).ToSQL(func(b *Builder[builderModel]) {
+		b.Find()
+	})
+	assert.Equal(t, "SELECT * FROM `builder_models` WHERE age > ?", sql)
+	assert.Equal(t, []any{18}, args)
+
+	sql, args = B[builderModel]("id = ?", 1).ToSQL(func(b *Builder[builderModel]) {
+		b.Update("name", "John")
+	})
+	assert.Equal(t, "UPDATE `builder_models` SET `name`=? WHERE id = ?", sql)
+	assert.Equal(t, []any{"John", 1}, args)
+
+	sql, args = B[builderModel]("id = ?", 1).ToSQL(func(b *Builder[builderModel]) {
+		b.HardDelete()
+	})
+	assert.Equal(t, "DELETE FROM `builder_models` WHERE id = ?", sql)
+	assert.Equal(t, []any{1}, args)
+}
+
+func TestUpdateInBatches(t *testing.T) {
+	dryRun(t)
+
+	var statements []string
+	i.Callback().Update().After("*").Register("test:record", func(db *gorm.DB) {
+		statements = append(statements, i.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	})
+
+	rows := []*builderModel{
+		{ID: 1, Name: "John", Age: 20},
+		{ID: 2, Name: "Jane", Age: 30},
+		{ID: 3, Name: "Jim", Age: 40},
+	}
+
+	UpdateInBatches(rows, []string{"Age"}, 2)
+
+	assert.Equal(t, []string{
+		"UPDATE `builder_models` SET `age`=CASE `id` WHEN 1 THEN 20 WHEN 2 THEN 30 END WHERE `id` IN (1,2)",
+		"UPDATE `builder_models` SET `age`=CASE `id` WHEN 3 THEN 40 END WHERE `id` = 3",
+	}, statements)
+}
+
+func TestFindByIDs(t *testing.T) {
+	dryRun(t)
+
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+
+	rows, missing := FindByIDsStrict[builderModel]([]int{3, 1})
+	assert.Empty(t, rows)
+	assert.Equal(t, []any{3, 1}, missing)
+
+	var missingIDsError *MissingIDsError
+	assert.ErrorAs(t, handled, &missingIDsError)
+	assert.Equal(t, []any{3, 1}, missingIDsError.IDs)
+
+	assert.Empty(t, FindByIDs[builderModel]([]int{}))
+
+	var queries []string
+	assert.Nil(t, i.Callback().Query().After("*").Register("test:record", func(db *gorm.DB) {
+		queries = append(queries, i.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	}))
+
+	B[builderModel]("age > ?", 18).OrWhere("name = ?", "John").FindByIDs([]int{3, 1})
+	assert.Equal(t, []string{
+		"SELECT * FROM `builder_models` WHERE (age > 18 OR name = 'John') AND `builder_models`.`id` IN (3,1)",
+	}, queries)
+}
+
+func TestMatchByKeys(t *testing.T) {
+	dryRun(t)
+
+	stmt := &gorm.Statement{DB: i}
+	assert.Nil(t, stmt.Parse(new(upsertModel)))
+
+	keys, err := lookUpFields(stmt.Schema, []string{"Email", "Name"})
+	assert.Nil(t, err)
+
+	values := []*upsertModel{
+		{Email: "ab", Name: "c"},
+		{Email: "a", Name: "bc"},
+		{Email: "John@Example.com ", Name: "x"},
+		{Email: "missing", Name: "x"},
+	}
+
+	rows := []*upsertModel{
+		{ID: 1, Email: "a", Name: "bc"},
+		{ID: 2, Email: "ab", Name: "c"},
+		{ID: 3, Email: "john@example.com", Name: "X"},
+	}
+
+	reloaded, missing := matchByKeys(stmt.Context, keys, values, rows)
+	assert.Equal(t, []*upsertModel{rows[1], rows[0], rows[2], values[3]}, reloaded)
+	assert.Equal(t, 1, missing)
+}
+
+func TestMustAffect(t *testing.T) {
+	dryRun(t)
+
+	var queries []string
+	assert.Nil(t, i.Callback().Query().After("*").Register("test:record", func(db *gorm.DB) {
+		queries = append(queries, db.Statement.SQL.String())
+	}))
+
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+
+	// Nothing is affected in dry run mode.
+	result := B[builderModel]("id = ?", 1).Update("name", "John")
+	assert.Equal(t, Result{}, result)
+	assert.Nil(t, handled)
+	assert.Empty(t, queries)
+
+	B[builderModel]("id = ?", 1).MustAffect().Update("name", "John")
+	assert.ErrorIs(t, handled, ErrNoRowsAffected)
+
+	handled = nil
+	B[builderModel]("id = ?", 1).MustAffect().Increment("age", 1)
+	assert.ErrorIs(t, handled, ErrNoRowsAffected)
+
+	handled = nil
+	B[builderModel]("id = ?", 1).MustAffect().HardDelete()
+	assert.ErrorIs(t, handled, ErrNoRowsAffected)
+}
+
+func TestToSQLUnsupported(t *testing.T) {
+	dryRun(t)
+
+	sql, _ := B[builderModel]("id = ?", 1).MustAffect().ToSQL(func(b *Builder[builderModel]) {
+		b.Update("name", "John")
+	})
+	assert.Equal(t, "UPDATE `builder_models` SET `name`=? WHERE id = ?", sql)
+
+	assert.PanicsWithValue(t, "Rows and Each can't be used with ToSQL", func() {
+		B[builderModel]().ToSQL(func(b *Builder[builderModel]) {
+			b.Each(func(*builderModel) error { return nil })
+		})
+	})
+}
+
+type stringKeyModel struct {
+	Code string `gorm:"primaryKey"`
+}
+
+func TestOrderByIDs(t *testing.T) {
+	dryRun(t)
+
+	parse := func(model any) *schema.Field {
+		stmt := &gorm.Statement{DB: i}
+		assert.Nil(t, stmt.Parse(model))
+		return stmt.Schema.PrioritizedPrimaryField
+	}
+
+	t.Run("integer keys", func(t *testing.T) {
+		rows := []*builderModel{{ID: 1}, {ID: 2}, {ID: 3}}
+
+		ordered, missing := orderByIDs(context.Background(), parse(new(builderModel)), []any{3, int64(1), uint8(4)}, rows)
+		assert.Equal(t, []*builderModel{rows[2], rows[0]}, ordered)
+		assert.Equal(t, []any{uint8(4)}, missing)
+	})
+
+	t.Run("string keys", func(t *testing.T) {
+		rows := []*stringKeyModel{{Code: "abc"}, {Code: "ABC "}, {Code: "Def"}}
+
+		ordered, missing := orderByIDs(context.Background(), parse(new(stringKeyModel)), []any{"ABC ", "def ", "abc", "ghi"}, rows)
+		assert.Equal(t, []*stringKeyModel{rows[1], rows[2], rows[0]}, ordered)
+		assert.Equal(t, []any{"ghi"}, missing)
+	})
+}
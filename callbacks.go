@@ -0,0 +1,91 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrLockOutsideTransaction = errors.New("locking clause used outside of a transaction")
+
+// registerCallbacks registers this package's callbacks with db. It is safe to call more than once
+// for the same db.
+func registerCallbacks(db *gorm.DB) error {
+	if db.Callback().Query().Get("db:check_locking") != nil {
+		return nil
+	}
+
+	callbacks := db.Callback()
+
+	return chain(
+		func() error { return callbacks.Query().Before("*").Register("db:check_closing", checkClosing) },
+		func() error { return callbacks.Row().Before("*").Register("db:check_closing", checkClosing) },
+		func() error { return callbacks.Create().Before("*").Register("db:check_closing", checkClosing) },
+		func() error { return callbacks.Update().Before("*").Register("db:check_closing", checkClosing) },
+		func() error { return callbacks.Delete().Before("*").Register("db:check_closing", checkClosing) },
+		func() error { return callbacks.Raw().Before("*").Register("db:check_closing", checkClosing) },
+		func() error {
+			return callbacks.Query().Before("gorm:query").Register("db:check_locking", checkLocking)
+		},
+		func() error {
+			return callbacks.Row().Before("gorm:row").Register("db:check_locking", checkLocking)
+		},
+		func() error {
+			return callbacks.Query().Before("gorm:query").Register("db:track_statement", trackStatement)
+		},
+		func() error {
+			return callbacks.Row().Before("gorm:row").Register("db:track_statement", trackStatement)
+		},
+		func() error { return callbacks.Query().After("*").Register("db:record_sql", recordSQL) },
+		func() error { return callbacks.Row().After("*").Register("db:record_sql", recordSQL) },
+		func() error { return callbacks.Create().After("*").Register("db:record_sql", recordSQL) },
+		func() error { return callbacks.Update().After("*").Register("db:record_sql", recordSQL) },
+		func() error { return callbacks.Delete().After("*").Register("db:record_sql", recordSQL) },
+		func() error { return callbacks.Raw().After("*").Register("db:record_sql", recordSQL) },
+		func() error { return callbacks.Query().After("*").Register("db:annotate_error", annotateError) },
+		func() error { return callbacks.Row().After("*").Register("db:annotate_error", annotateError) },
+		func() error { return callbacks.Create().After("*").Register("db:annotate_error", annotateError) },
+		func() error { return callbacks.Update().After("*").Register("db:annotate_error", annotateError) },
+		func() error { return callbacks.Delete().After("*").Register("db:annotate_error", annotateError) },
+		func() error { return callbacks.Raw().After("*").Register("db:annotate_error", annotateError) },
+		func() error { return callbacks.Query().After("*").Register("db:count_query", countQuery) },
+		func() error { return callbacks.Row().After("*").Register("db:count_query", countQuery) },
+		func() error { return callbacks.Create().After("*").Register("db:count_query", countQuery) },
+		func() error { return callbacks.Update().After("*").Register("db:count_query", countQuery) },
+		func() error { return callbacks.Delete().After("*").Register("db:count_query", countQuery) },
+		func() error { return callbacks.Raw().After("*").Register("db:count_query", countQuery) },
+	)
+}
+
+// checkLocking adds an error to db if it has a locking clause but is not running within a
+// transaction, in which case the lock would be released as soon as the query finished.
+func checkLocking(db *gorm.DB) {
+	if _, ok := db.Statement.Clauses["FOR"]; ok && !inTransaction(db) {
+		_ = db.AddError(ErrLockOutsideTransaction)
+	}
+}
+
+// The statement setting that holds the *sqlRecorder used by ToSQL.
+const sqlRecorderKey = "db:sql_recorder"
+
+// sqlRecorder holds the SQL and args of the first statement built by a query.
+type sqlRecorder struct {
+	sql  string
+	args []any
+	done bool
+}
+
+// recordSQL records the SQL and args of db's statement if it has a *sqlRecorder that hasn't yet
+// recorded a statement.
+func recordSQL(db *gorm.DB) {
+	value, ok := db.Get(sqlRecorderKey)
+	if !ok {
+		return
+	}
+
+	if recorder := value.(*sqlRecorder); !recorder.done {
+		recorder.sql = db.Statement.SQL.String()
+		recorder.args = append([]any{}, db.Statement.Vars...)
+		recorder.done = true
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm/schema"
+)
+
+// model is a struct type found in the package.
+type model struct {
+	name string
+	spec *ast.StructType
+	file *ast.File
+}
+
+// column is a single column of a model.
+type column struct {
+	field string // The name of the generated field.
+	name  string // The name of the database column.
+	typ   string // The Go type of the column's values.
+}
+
+type generator struct {
+	models  map[string]*model
+	imports map[string]string // Maps package names used in column types to import paths.
+	naming  schema.NamingStrategy
+}
+
+// generate returns the source of a file for the package in dir that declares a variable named
+// varName containing the columns of the models named types, or all exported struct types if types
+// is empty. The file named output is ignored when parsing the package.
+func generate(dir string, output string, types []string, varName string) ([]byte, error) {
+	fset := token.NewFileSet()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		pkgName string
+		files   []*ast.File
+	)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		if pkgName != "" && file.Name.Name != pkgName {
+			return nil, fmt.Errorf("found packages %s and %s in %s", pkgName, file.Name.Name, dir)
+		}
+
+		pkgName = file.Name.Name
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files found in %s", dir)
+	}
+
+	g := &generator{
+		models:  map[string]*model{},
+		imports: map[string]string{},
+	}
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if st, ok := spec.Type.(*ast.StructType); ok && spec.TypeParams == nil {
+					g.models[spec.Name.Name] = &model{name: spec.Name.Name, spec: st, file: file}
+				}
+			}
+		}
+	}
+
+	if len(types) == 0 {
+		for name := range g.models {
+			if ast.IsExported(name) {
+				types = append(types, name)
+			}
+		}
+		sort.Strings(types)
+	}
+
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no models found in %s", dir)
+	}
+
+	columns := map[string][]column{}
+	for _, name := range types {
+		m, ok := g.models[name]
+		if !ok {
+			return nil, fmt.Errorf("model not found: %s", name)
+		}
+
+		if columns[name], err = g.columns(m.spec, m.file, "", ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return g.render(pkgName, types, varName, columns)
+}
+
+// columns returns the columns of st, which was declared in file. Embedded fields are expanded,
+// with fieldPrefix and columnPrefix prepended to the names of their columns.
+func (self *generator) columns(st *ast.StructType, file *ast.File, fieldPrefix string, columnPrefix string) ([]column, error) {
+	var columns []column
+
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s)
+		}
+
+		settings := schema.ParseTagSetting(tag.Get("gorm"), ";")
+		if v, ok := settings["-"]; ok && (v == "-" || v == "all") {
+			continue
+		}
+
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+
+		_, isEmbedded := settings["EMBEDDED"]
+
+		if len(field.Names) == 0 || isEmbedded {
+			nextFieldPrefix := fieldPrefix
+			if len(field.Names) > 0 {
+				nextFieldPrefix += field.Names[0].Name
+			}
+
+			embedded, err := self.embeddedColumns(typ, file, nextFieldPrefix, columnPrefix+settings["EMBEDDEDPREFIX"])
+			if err != nil {
+				return nil, err
+			}
+
+			columns = append(columns, embedded...)
+			continue
+		}
+
+		if self.isAssociation(typ, settings) {
+			continue
+		}
+
+		typeName, err := self.typeString(field.Type, file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+
+			columnName := settings["COLUMN"]
+			if columnName == "" {
+				columnName = self.naming.ColumnName("", name.Name)
+			}
+
+			columns = append(columns, column{
+				field: fieldPrefix + name.Name,
+				name:  columnPrefix + columnName,
+				typ:   typeName,
+			})
+		}
+	}
+
+	return columns, nil
+}
+
+// embeddedColumns returns the columns of the embedded struct typ.
+func (self *generator) embeddedColumns(typ ast.Expr, file *ast.File, fieldPrefix string, columnPrefix string) ([]column, error) {
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		if m, ok := self.models[typ.Name]; ok {
+			return self.columns(m.spec, m.file, fieldPrefix, columnPrefix)
+		}
+
+	case *ast.SelectorExpr:
+		if pkg, ok := typ.X.(*ast.Ident); ok && typ.Sel.Name == "Model" && self.importPath(pkg.Name, file) == "gorm.io/gorm" {
+			self.imports["time"] = "time"
+			self.imports[pkg.Name] = "gorm.io/gorm"
+
+			return []column{
+				{field: fieldPrefix + "ID", name: columnPrefix + "id", typ: "uint"},
+				{field: fieldPrefix + "CreatedAt", name: columnPrefix + "created_at", typ: "time.Time"},
+				{field: fieldPrefix + "UpdatedAt", name: columnPrefix + "updated_at", typ: "time.Time"},
+				{field: fieldPrefix + "DeletedAt", name: columnPrefix + "deleted_at", typ: pkg.Name + ".DeletedAt"},
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported embedded type: %s", types.ExprString(typ))
+}
+
+// isAssociation returns whether a field of type typ is likely to be an association rather than a
+// column.
+func (self *generator) isAssociation(typ ast.Expr, settings map[string]string) bool {
+	if _, ok := settings["SERIALIZER"]; ok {
+		return false
+	}
+
+	for _, key := range []string{"FOREIGNKEY", "REFERENCES", "MANY2MANY", "POLYMORPHIC"} {
+		if _, ok := settings[key]; ok {
+			return true
+		}
+	}
+
+	switch typ := typ.(type) {
+	case *ast.ArrayType:
+		elt, ok := typ.Elt.(*ast.Ident)
+		return !ok || (elt.Name != "byte" && elt.Name != "uint8")
+
+	case *ast.Ident:
+		_, ok := self.models[typ.Name]
+		return ok
+	}
+
+	return false
+}
+
+// typeString returns typ as a string, recording the imports that it needs.
+func (self *generator) typeString(typ ast.Expr, file *ast.File) (string, error) {
+	var err error
+
+	ast.Inspect(typ, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok {
+				if importPath := self.importPath(pkg.Name, file); importPath != "" {
+					self.imports[pkg.Name] = importPath
+				} else {
+					err = fmt.Errorf("unknown package: %s", pkg.Name)
+				}
+			}
+			return false
+		}
+		return true
+	})
+
+	return types.ExprString(typ), err
+}
+
+// importPath returns the path of the package imported by file with the name name.
+func (self *generator) importPath(name string, file *ast.File) string {
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+
+		if spec.Name != nil {
+			if spec.Name.Name == name {
+				return importPath
+			}
+			continue
+		}
+
+		if guessPackageName(importPath) == name {
+			return importPath
+		}
+	}
+
+	return ""
+}
+
+// guessPackageName guesses the name of the package at importPath from the last element of the path,
+// ignoring any major version suffix.
+func guessPackageName(importPath string) string {
+	name := path.Base(importPath)
+
+	if len(name) > 1 && name[0] == 'v' && strings.TrimLeft(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+
+	name = strings.TrimPrefix(name, "go-")
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func (self *generator) render(pkgName string, types []string, varName string, columns map[string][]column) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "// Code generated by dbcols. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+
+	buf.WriteString("import (\n")
+	buf.WriteString("\t\"crdx.org/db\"\n")
+
+	var names []string
+	for name := range self.imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		importPath := self.imports[name]
+		if guessPackageName(importPath) == name {
+			fmt.Fprintf(&buf, "\t%q\n", importPath)
+		} else {
+			fmt.Fprintf(&buf, "\t%s %q\n", name, importPath)
+		}
+	}
+
+	buf.WriteString(")\n\n")
+
+	fmt.Fprintf(&buf, "// %s contains typed column references for each model.\n", varName)
+	fmt.Fprintf(&buf, "var %s = struct {\n", varName)
+	for _, name := range types {
+		fmt.Fprintf(&buf, "\t%s %s\n", name, columnsTypeName(name))
+	}
+	buf.WriteString("}{\n")
+	for _, name := range types {
+		fmt.Fprintf(&buf, "\t%s: %s{\n", name, columnsTypeName(name))
+		for _, column := range columns[name] {
+			fmt.Fprintf(&buf, "\t\t%s: db.NewField[%s, %s](%q),\n", column.field, name, column.typ, column.name)
+		}
+		buf.WriteString("\t},\n")
+	}
+	buf.WriteString("}\n")
+
+	for _, name := range types {
+		fmt.Fprintf(&buf, "\ntype %s struct {\n", columnsTypeName(name))
+		for _, column := range columns[name] {
+			fmt.Fprintf(&buf, "\t%s db.Field[%s, %s]\n", column.field, name, column.typ)
+		}
+		buf.WriteString("}\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// columnsTypeName returns the name of the generated type that holds the columns of model.
+func columnsTypeName(model string) string {
+	r := []rune(model)
+	return string(unicode.ToLower(r[0])) + string(r[1:]) + "Columns"
+}
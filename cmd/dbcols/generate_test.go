@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testModels = `package models
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Base struct {
+	ID        uint
+	CreatedAt time.Time
+}
+
+type Address struct {
+	Street string
+}
+
+type User struct {
+	Base
+	Email     string ` + "`gorm:\"uniqueIndex\"`" + `
+	FullName  string ` + "`gorm:\"column:name\"`" + `
+	Nickname  sql.NullString
+	Address   Address ` + "`gorm:\"embedded;embeddedPrefix:address_\"`" + `
+	Posts     []Post
+	Ignored   string ` + "`gorm:\"-\"`" + `
+	internal  string
+}
+
+type Post struct {
+	gorm.Model
+	UserID uint
+	User   User
+	Body   []byte
+}
+`
+
+const expectedOutput = `// Code generated by dbcols. DO NOT EDIT.
+
+package models
+
+import (
+	"crdx.org/db"
+	"database/sql"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Cols contains typed column references for each model.
+var Cols = struct {
+	User userColumns
+	Post postColumns
+}{
+	User: userColumns{
+		ID:            db.NewField[User, uint]("id"),
+		CreatedAt:     db.NewField[User, time.Time]("created_at"),
+		Email:         db.NewField[User, string]("email"),
+		FullName:      db.NewField[User, string]("name"),
+		Nickname:      db.NewField[User, sql.NullString]("nickname"),
+		AddressStreet: db.NewField[User, string]("address_street"),
+	},
+	Post: postColumns{
+		ID:        db.NewField[Post, uint]("id"),
+		CreatedAt: db.NewField[Post, time.Time]("created_at"),
+		UpdatedAt: db.NewField[Post, time.Time]("updated_at"),
+		DeletedAt: db.NewField[Post, gorm.DeletedAt]("deleted_at"),
+		UserID:    db.NewField[Post, uint]("user_id"),
+		Body:      db.NewField[Post, []byte]("body"),
+	},
+}
+
+type userColumns struct {
+	ID            db.Field[User, uint]
+	CreatedAt     db.Field[User, time.Time]
+	Email         db.Field[User, string]
+	FullName      db.Field[User, string]
+	Nickname      db.Field[User, sql.NullString]
+	AddressStreet db.Field[User, string]
+}
+
+type postColumns struct {
+	ID        db.Field[Post, uint]
+	CreatedAt db.Field[Post, time.Time]
+	UpdatedAt db.Field[Post, time.Time]
+	DeletedAt db.Field[Post, gorm.DeletedAt]
+	UserID    db.Field[Post, uint]
+	Body      db.Field[Post, []byte]
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "models.go"), []byte(testModels), 0o644))
+
+	src, err := generate(dir, "cols_gen.go", []string{"User", "Post"}, "Cols")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedOutput, string(src))
+
+	_, err = generate(dir, "cols_gen.go", []string{"Missing"}, "Cols")
+	assert.NotNil(t, err)
+}
+
+func TestGuessPackageName(t *testing.T) {
+	assert.Equal(t, "gorm", guessPackageName("gorm.io/gorm"))
+	assert.Equal(t, "uuid", guessPackageName("github.com/google/uuid"))
+	assert.Equal(t, "pgx", guessPackageName("github.com/jackc/pgx/v5"))
+	assert.Equal(t, "sqlite3", guessPackageName("github.com/mattn/go-sqlite3"))
+}
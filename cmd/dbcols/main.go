@@ -0,0 +1,64 @@
+// Command dbcols generates typed column references (see db.Field) for the models in a package, so
+// that renaming a struct field causes queries that refer to it to fail to compile rather than fail
+// at runtime.
+//
+// Add a go:generate directive to the package that contains the models:
+//
+//	//go:generate go run crdx.org/db/cmd/dbcols -type User,Post
+//
+// Running go generate then writes cols_gen.go to the package, which contains a variable named Cols
+// with a field for each model, which in turn has a db.Field for each column.
+//
+//	db.B[User]().Filter(Cols.User.Email.Eq("john@example.com")).Order(Cols.User.ID.Desc())
+//
+// Flags:
+//
+//	-type    comma-separated list of models (default: all exported struct types)
+//	-output  output file name (default: cols_gen.go)
+//	-var     name of the generated variable (default: Cols)
+//
+// Column names are derived the same way as gorm's default naming strategy, taking into account
+// column, embedded, embeddedPrefix and "-" tags, and expanding embedded structs such as gorm.Model.
+// Fields that look like associations (structs from the same package and slices) are skipped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("dbcols: ")
+
+	var (
+		typeNames = flag.String("type", "", "comma-separated list of models (default: all exported struct types)")
+		output    = flag.String("output", "cols_gen.go", "output file name")
+		varName   = flag.String("var", "Cols", "name of the generated variable")
+	)
+
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+
+	src, err := generate(dir, filepath.Base(*output), types, *varName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, *output), src, 0o644); err != nil {
+		log.Fatal(fmt.Errorf("write output: %w", err))
+	}
+}
@@ -1,78 +1,190 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type Config struct {
-	Name          string          // The database name.
-	User          string          // The database username.
-	Pass          string          // The database password.
-	Host          string          // The database hostname.
-	Socket        string          // The database socket path.
-	CharSet       string          // The database character set.
-	TimeZone      string          // The database timezone.
-	Models        []Model         // A list of models to migrate.
-	Migrations    []*Migration    // A list of manual migrations to run.
-	Debug         bool            // Whether to log queries.
-	Colour        bool            // Whether to display colour in debugging output.
-	Fresh         bool            // Whether to drop and recreate the database (for tests).
-	ErrorHandler  func(err error) // A function to run if a database error occurs.
-	SlowThreshold time.Duration   // Threshold for queries to be considered slow.
-	Seed          func() error
+	Name                string                  // The database name.
+	User                string                  // The database username.
+	Pass                string                  // The database password.
+	Host                string                  // The database hostname.
+	Socket              string                  // The database socket path.
+	CharSet             string                  // The database character set.
+	Collation           string                  // The database collation.
+	TimeZone            string                  // The database timezone.
+	Replicas            []string                // The DSNs of read replicas (optional; see ReplicaDSN).
+	ReplicaSelection    ReplicaSelection        // How to choose which replica to send a read to.
+	MaxOpenConns        int                     // The maximum number of open connections (per pool).
+	MaxIdleConns        int                     // The maximum number of idle connections (per pool).
+	ConnMaxLifetime     time.Duration           // The maximum amount of time a connection may be reused.
+	ConnMaxIdleTime     time.Duration           // The maximum amount of time a connection may be idle.
+	PoolWaitThreshold   time.Duration           // Log a warning if queries wait longer than this for a connection on average.
+	StartupTimeout      time.Duration           // How long Init waits for the database to be reachable (0 to fail immediately).
+	StartupBackoff      time.Duration           // The delay before Init first retries connecting (default: 500ms).
+	Context             context.Context         // Cancels Init waiting for the database to be reachable (optional).
+	Models              []Model                 // A list of models to migrate.
+	Migrations          []*Migration            // A list of manual migrations to run.
+	Debug               bool                    // Whether to log queries.
+	Colour              bool                    // Whether to display colour in debugging output.
+	Fresh               bool                    // Whether to drop and recreate the database (for tests).
+	FreshAllowedNames   string                  // A pattern that the database name must match for Fresh (default: "_test$").
+	AllowDestructive    bool                    // Whether to allow Fresh regardless of the database name.
+	SentinelTable       string                  // A table that must have no rows for Fresh to drop the database.
+	ErrorHandler        func(err error)         // A function to run if a database error occurs.
+	ContextErrorHandler func(ctx *ErrorContext) // Like ErrorHandler but with context (takes precedence).
+	RedactErrorArgs     bool                    // Whether to redact query args passed to ContextErrorHandler.
+	SlowThreshold       time.Duration           // Threshold for queries to be considered slow.
+	ExplainSlow         bool                    // Whether to log the query plan of slow reads.
+	Retry               *RetryPolicy            // How to retry operations that fail with a transient error.
+	Seed                func() error
 }
 
 // PrimaryDSN returns the DSN with the database name specified.
 func (self *Config) PrimaryDSN() string {
-	dsn, _ := self.buildDSNs()
-	return dsn
+	return self.FormatDSN(self.Name)
 }
 
 // FallbackDSN returns the DSN without the database name specified.
 func (self *Config) FallbackDSN() string {
-	_, dsn := self.buildDSNs()
-	return dsn
+	return self.FormatDSN("")
 }
 
-// buildDSNs builds two DSNs: one with the database name ("primary"), and one without ("fallback).
-func (self *Config) buildDSNs() (primaryDSN string, fallbackDSN string) {
+// FormatDSN returns the DSN for connecting to the database named name, or to the server without
+// selecting a database if name is empty.
+func (self *Config) FormatDSN(name string) string {
 	// https://github.com/go-sql-driver/mysql#dsn-data-source-name
-	// {DB_USER}:{DB_PASS}@unix({DB_SOCK})/{DB_NAME}?parseTime=True&charset={DB_CHARSET}&loc={DB_TZ}
-	// {DB_USER}:{DB_PASS}@tcp({DB_HOST})/{DB_NAME}?parseTime=True&charset={DB_CHARSET}&loc={DB_TZ}
-
-	builder := strings.Builder{}
+	dsn := mysql.NewConfig()
 
-	builder.WriteString(self.User)
+	dsn.User = self.User
+	dsn.Passwd = self.Pass
+	dsn.DBName = name
 
-	if self.Pass != "" {
-		builder.WriteString(":")
-		builder.WriteString(self.Pass)
+	if self.Socket != "" {
+		dsn.Net = "unix"
+		dsn.Addr = self.Socket
+	} else if self.Host != "" {
+		dsn.Net = "tcp"
+		dsn.Addr = self.Host
 	}
 
-	builder.WriteString("@")
+	self.setOptions(dsn)
 
-	if self.Socket != "" {
-		builder.WriteString(fmt.Sprintf("unix(%s)", self.Socket))
-	} else if self.Host != "" {
-		builder.WriteString(fmt.Sprintf("tcp(%s)", self.Host))
+	return dsn.FormatDSN()
+}
+
+// ReplicaDSN returns the DSN of the replica at index n of Replicas, with its character set,
+// collation, timezone and other options replaced by those of the primary, so that values are read
+// back the same way from either.
+func (self *Config) ReplicaDSN(n int) (string, error) {
+	dsn, err := mysql.ParseDSN(self.Replicas[n])
+	if err != nil {
+		return "", err
 	}
 
-	builder.WriteString("/%s?parseTime=True")
+	self.setOptions(dsn)
+
+	return dsn.FormatDSN(), nil
+}
 
+// setOptions sets the connection options of dsn from the config.
+func (self *Config) setOptions(dsn *mysql.Config) {
+	dsn.ParseTime = true
+	dsn.ClientFoundRows = true // See Result.
+	dsn.Collation = self.Collation
+
+	delete(dsn.Params, "charset")
 	if self.CharSet != "" {
-		builder.WriteString("&charset=" + self.CharSet)
+		if dsn.Params == nil {
+			dsn.Params = map[string]string{}
+		}
+		dsn.Params["charset"] = self.CharSet
 	}
 
+	dsn.Loc = time.UTC
 	if self.TimeZone != "" {
-		builder.WriteString("&loc=" + self.TimeZone)
+		// An invalid timezone is reported by Validate.
+		if loc, err := time.LoadLocation(self.TimeZone); err == nil {
+			dsn.Loc = loc
+		}
 	}
+}
+
+var charSetPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
 
-	s := builder.String()
+// Validate returns an error describing any problems with the config.
+func (self *Config) Validate() error {
+	var errs []error
+
+	invalid := func(field string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("invalid %s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	// https://dev.mysql.com/doc/refman/8.0/en/identifiers.html
+	switch {
+	case self.Name == "":
+		invalid("Name", "must not be empty")
+	case len(self.Name) > 64:
+		invalid("Name", "must be at most 64 characters")
+	case strings.HasSuffix(self.Name, " "):
+		invalid("Name", "must not end with a space")
+	case strings.ContainsAny(self.Name, "/\\.\x00"):
+		invalid("Name", "must not contain '/', '\\', '.' or NUL")
+	}
+
+	if self.Host != "" && self.Socket != "" {
+		invalid("Socket", "must not be set as well as Host")
+	}
 
-	primaryDSN = fmt.Sprintf(s, self.Name)
-	fallbackDSN = fmt.Sprintf(s, "")
+	if self.CharSet != "" && !charSetPattern.MatchString(self.CharSet) {
+		invalid("CharSet", "%q", self.CharSet)
+	}
+
+	if self.Collation != "" && !charSetPattern.MatchString(self.Collation) {
+		invalid("Collation", "%q", self.Collation)
+	}
+
+	if self.TimeZone != "" {
+		if _, err := time.LoadLocation(self.TimeZone); err != nil {
+			invalid("TimeZone", "%s", err)
+		}
+	}
+
+	for n, replica := range self.Replicas {
+		if _, err := mysql.ParseDSN(replica); err != nil {
+			invalid(fmt.Sprintf("Replicas[%d]", n), "%s", err)
+		}
+	}
+
+	if self.MaxOpenConns < 0 {
+		invalid("MaxOpenConns", "must not be negative")
+	}
+
+	if self.MaxIdleConns < 0 {
+		invalid("MaxIdleConns", "must not be negative")
+	}
+
+	if self.FreshAllowedNames != "" {
+		if _, err := regexp.Compile(self.FreshAllowedNames); err != nil {
+			invalid("FreshAllowedNames", "%s", err)
+		}
+	}
+
+	if self.Retry != nil && self.Retry.MaxAttempts < 1 {
+		invalid("Retry", "MaxAttempts must be at least 1")
+	}
+
+	return errors.Join(errs...)
+}
 
-	return
+// quoteIdentifier returns name quoted for use as an identifier in SQL.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
 }
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDSN(t *testing.T) {
+	config := &Config{
+		Name:     "blog",
+		User:     "root",
+		Pass:     "p@ss/word",
+		Host:     "localhost:3306",
+		CharSet:  "utf8mb4",
+		TimeZone: "Europe/London",
+	}
+
+	dsn, err := mysql.ParseDSN(config.PrimaryDSN())
+	assert.Nil(t, err)
+	assert.Equal(t, "root", dsn.User)
+	assert.Equal(t, "p@ss/word", dsn.Passwd)
+	assert.Equal(t, "tcp", dsn.Net)
+	assert.Equal(t, "localhost:3306", dsn.Addr)
+	assert.Equal(t, "blog", dsn.DBName)
+	assert.Equal(t, "Europe/London", dsn.Loc.String())
+	assert.Equal(t, "utf8mb4", dsn.Params["charset"])
+	assert.True(t, dsn.ParseTime)
+	assert.True(t, dsn.ClientFoundRows)
+
+	assert.Equal(t, "root:p@ss/word@unix(/tmp/mysql.sock)/?clientFoundRows=true&parseTime=true", (&Config{User: "root", Pass: "p@ss/word", Socket: "/tmp/mysql.sock"}).FallbackDSN())
+}
+
+func TestReplicaDSN(t *testing.T) {
+	config := &Config{
+		CharSet:   "utf8mb4",
+		Collation: "utf8mb4_unicode_ci",
+		TimeZone:  "Europe/London",
+		Replicas:  []string{"reader:secret@tcp(replica:3306)/blog?charset=latin1&loc=Local&timeout=5s", "invalid"},
+	}
+
+	replica, err := config.ReplicaDSN(0)
+	assert.Nil(t, err)
+
+	dsn, err := mysql.ParseDSN(replica)
+	assert.Nil(t, err)
+	assert.Equal(t, "reader", dsn.User)
+	assert.Equal(t, "replica:3306", dsn.Addr)
+	assert.Equal(t, "blog", dsn.DBName)
+	assert.Equal(t, 5*time.Second, dsn.Timeout)
+	assert.Equal(t, "utf8mb4", dsn.Params["charset"])
+	assert.Equal(t, "utf8mb4_unicode_ci", dsn.Collation)
+	assert.Equal(t, "Europe/London", dsn.Loc.String())
+	assert.True(t, dsn.ParseTime)
+	assert.True(t, dsn.ClientFoundRows)
+
+	_, err = config.ReplicaDSN(1)
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		config   *Config
+		expected string
+	}{
+		{config: &Config{Name: "blog", CharSet: "utf8mb4", Collation: "utf8mb4_unicode_ci", TimeZone: "UTC"}},
+		{config: &Config{}, expected: "invalid Name: must not be empty"},
+		{config: &Config{Name: "blog.test"}, expected: "invalid Name: must not contain '/', '\\', '.' or NUL"},
+		{config: &Config{Name: "blog", Host: "localhost", Socket: "/tmp/mysql.sock"}, expected: "invalid Socket: must not be set as well as Host"},
+		{config: &Config{Name: "blog", CharSet: "utf8; DROP"}, expected: `invalid CharSet: "utf8; DROP"`},
+		{config: &Config{Name: "blog", TimeZone: "Nowhere/Special"}, expected: "invalid TimeZone: unknown time zone Nowhere/Special"},
+		{config: &Config{Name: "blog", MaxOpenConns: -1, Retry: &RetryPolicy{}}, expected: "invalid MaxOpenConns: must not be negative\ninvalid Retry: MaxAttempts must be at least 1"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("Case%d", i+1), func(t *testing.T) {
+			err := testCase.config.Validate()
+			if testCase.expected == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "`blog`", quoteIdentifier("blog"))
+	assert.Equal(t, "`blog``; DROP DATABASE x; --`", quoteIdentifier("blog`; DROP DATABASE x; --"))
+}
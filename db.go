@@ -1,9 +1,15 @@
 package db
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 // Instance returns the internal instance of *gorm.DB.
@@ -11,9 +17,12 @@ func Instance() *gorm.DB {
 	return i
 }
 
-// SetInstance sets the internal instance of *gorm.DB.
+// SetInstance sets the internal instance of *gorm.DB. Its DSN should set clientFoundRows for rows
+// affected to be counted the same way as with Init (see Result).
 func SetInstance(value *gorm.DB) {
 	i = value
+	closing.Store(false)
+	must0(registerCallbacks(value))
 }
 
 // Interface Model represents an instance of a model object. These will normally be implemented
@@ -52,6 +61,34 @@ func FirstD[T any](id any) (*T, bool) {
 	return ForD[T](id).First()
 }
 
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+// FindByIDs
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+
+// FindByIDs[T] returns the T with each of the specified IDs, in the same order as ids. IDs that
+// aren't found are skipped.
+func FindByIDs[T any, ID any](ids []ID) []*T {
+	return B[T]().FindByIDs(ids)
+}
+
+// FindByIDsD[T] returns the T with each of the specified IDs (in debug mode), in the same order as
+// ids. IDs that aren't found are skipped.
+func FindByIDsD[T any, ID any](ids []ID) []*T {
+	return B[T]().Debug().FindByIDs(ids)
+}
+
+// FindByIDsStrict[T] is like FindByIDs[T], but if any ids aren't found then the error handler is
+// called with a *MissingIDsError, and the missing ids are returned.
+func FindByIDsStrict[T any, ID any](ids []ID) ([]*T, []any) {
+	return B[T]().FindByIDsStrict(ids)
+}
+
+// FindByIDsStrictD[T] is like FindByIDsD[T], but if any ids aren't found then the error handler is
+// called with a *MissingIDsError, and the missing ids are returned.
+func FindByIDsStrictD[T any, ID any](ids []ID) ([]*T, []any) {
+	return B[T]().Debug().FindByIDsStrict(ids)
+}
+
 // —————————————————————————————————————————————————————————————————————————————————————————————————
 // Save
 // —————————————————————————————————————————————————————————————————————————————————————————————————
@@ -179,6 +216,253 @@ func CreateInBatchesD[T any](values []*T, batchSize int) []*T {
 	return createInBatches(i.Debug(), values, batchSize)
 }
 
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+// Upsert
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+
+func upsert[T any](i *gorm.DB, values []*T, conflictColumns []string, updateColumns []string, batchSize int) []*T {
+	if len(conflictColumns) == 0 {
+		panic("conflictColumns must not be empty")
+	}
+
+	if len(values) == 0 {
+		return values
+	}
+
+	stmt := &gorm.Statement{DB: i}
+	must0(stmt.Parse(new(T)))
+
+	keys := must(lookUpFields(stmt.Schema, conflictColumns))
+
+	onConflict := clause.OnConflict{}
+	for _, key := range keys {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: key.DBName})
+	}
+
+	if len(updateColumns) > 0 {
+		var names []string
+		for _, field := range must(lookUpFields(stmt.Schema, updateColumns)) {
+			names = append(names, field.DBName)
+		}
+		onConflict.DoUpdates = clause.AssignmentColumns(names)
+	} else {
+		onConflict.UpdateAll = true
+	}
+
+	must0(i.Clauses(onConflict).CreateInBatches(values, batchSize).Error)
+
+	return reloadByKeys(i, values, keys, batchSize)
+}
+
+// reloadByKeys returns the current version of each of values, looked up by the values of keys in
+// batches of batchSize. The upserted primary key isn't reliably known after an update so this is
+// the only way to get the final rows.
+func reloadByKeys[T any](i *gorm.DB, values []*T, keys []*schema.Field, batchSize int) []*T {
+	ctx := i.Statement.Context
+
+	if batchSize < 1 {
+		batchSize = len(values)
+	}
+
+	var rows []*T
+	for start := 0; start < len(values); start += batchSize {
+		var conditions []clause.Expression
+		for _, value := range values[start:min(start+batchSize, len(values))] {
+			var and []clause.Expression
+			for _, key := range keys {
+				v, _ := key.ValueOf(ctx, reflect.ValueOf(value).Elem())
+				and = append(and, clause.Eq{Column: clause.Column{Name: key.DBName}, Value: v})
+			}
+			conditions = append(conditions, clause.And(and...))
+		}
+
+		var batch []*T
+		must0(i.Model(new(T)).Where(clause.Or(conditions...)).Find(&batch).Error)
+		rows = append(rows, batch...)
+	}
+
+	reloaded, missing := matchByKeys(ctx, keys, values, rows)
+	if missing > 0 {
+		must0(fmt.Errorf("%d of %d upserted rows not found when reloading", missing, len(values)))
+	}
+
+	return reloaded
+}
+
+// matchByKeys returns the row from rows that matches each of values on keys, and the number of
+// values that had no match (which are returned as they are).
+func matchByKeys[T any](ctx context.Context, keys []*schema.Field, values []*T, rows []*T) ([]*T, int) {
+	keysOf := func(row *T) []any {
+		s := make([]any, len(keys))
+		for n, key := range keys {
+			s[n], _ = key.ValueOf(ctx, reflect.ValueOf(row).Elem())
+		}
+		return s
+	}
+
+	index := newRowIndex(rows, keysOf)
+
+	reloaded := make([]*T, len(values))
+	missing := 0
+
+	for n, value := range values {
+		if row, ok := index.find(keysOf(value)...); ok {
+			reloaded[n] = row
+		} else {
+			reloaded[n] = value
+			missing++
+		}
+	}
+
+	return reloaded, missing
+}
+
+// Upsert creates a new model, or if it conflicts with an existing row on conflictColumns, updates
+// updateColumns of that row instead (or all columns if updateColumns is empty). Returns the final
+// version of the row.
+//
+// On MySQL this compiles to INSERT ... ON DUPLICATE KEY UPDATE, which ignores conflictColumns and
+// considers all unique keys, so conflictColumns should match one of the table's unique keys. The
+// row is reloaded by the values of conflictColumns, and the error handler is called if it can't be
+// found.
+//
+// Examples:
+//
+//	Upsert(&Model{Email: "john@example.com", Name: "John"}, []string{"email"}, []string{"name"})
+//	Upsert(&Model{Email: "john@example.com", Name: "John"}, []string{"email"}, nil)
+func Upsert[T any](value *T, conflictColumns []string, updateColumns []string) *T {
+	return upsert(i, []*T{value}, conflictColumns, updateColumns, 1)[0]
+}
+
+// UpsertD creates a new model (in debug mode), or if it conflicts with an existing row on
+// conflictColumns, updates updateColumns of that row instead (or all columns if updateColumns is
+// empty). Returns the final version of the row.
+func UpsertD[T any](value *T, conflictColumns []string, updateColumns []string) *T {
+	return upsert(i.Debug(), []*T{value}, conflictColumns, updateColumns, 1)[0]
+}
+
+// UpsertInBatches is like Upsert but for multiple models in batches of batchSize. Returns the final
+// version of each row, in the same order as values.
+func UpsertInBatches[T any](values []*T, conflictColumns []string, updateColumns []string, batchSize int) []*T {
+	return upsert(i, values, conflictColumns, updateColumns, batchSize)
+}
+
+// UpsertInBatchesD is like UpsertD but for multiple models in batches of batchSize. Returns the
+// final version of each row, in the same order as values.
+func UpsertInBatchesD[T any](values []*T, conflictColumns []string, updateColumns []string, batchSize int) []*T {
+	return upsert(i.Debug(), values, conflictColumns, updateColumns, batchSize)
+}
+
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+// UpdateInBatches
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+
+func updateInBatches[T any](i *gorm.DB, rows []*T, columns []string, batchSize int) int64 {
+	if len(columns) == 0 {
+		panic("columns must not be empty")
+	}
+
+	if batchSize < 1 {
+		panic("batchSize must be greater than zero")
+	}
+
+	if len(rows) == 0 {
+		return 0
+	}
+
+	stmt := &gorm.Statement{DB: i}
+	must0(stmt.Parse(new(T)))
+
+	primaryKey := stmt.Schema.PrioritizedPrimaryField
+	if primaryKey == nil {
+		panic("model has no primary key")
+	}
+
+	fields := must(lookUpFields(stmt.Schema, columns))
+
+	var n int64
+
+	run := func(tx *gorm.DB) error {
+		for start := 0; start < len(rows); start += batchSize {
+			batch := rows[start:min(start+batchSize, len(rows))]
+
+			res := tx.Model(new(T)).
+				Where(clause.IN{Column: clause.Column{Name: primaryKey.DBName}, Values: primaryKeyValues(tx, primaryKey, batch)}).
+				Updates(caseUpdates(tx, primaryKey, fields, batch))
+
+			if res.Error != nil {
+				return res.Error
+			}
+
+			n += res.RowsAffected
+		}
+		return nil
+	}
+
+	if i.SkipDefaultTransaction {
+		must0(run(i))
+	} else {
+		must0(i.Transaction(run))
+	}
+
+	return n
+}
+
+// primaryKeyValues returns the values of primaryKey for each of rows.
+func primaryKeyValues[T any](i *gorm.DB, primaryKey *schema.Field, rows []*T) []any {
+	ids := make([]any, len(rows))
+	for n, row := range rows {
+		ids[n], _ = primaryKey.ValueOf(i.Statement.Context, reflect.ValueOf(row).Elem())
+	}
+	return ids
+}
+
+// caseUpdates returns a map of the columns of fields to CASE expressions that set each row, looked
+// up by primaryKey, to its own value.
+func caseUpdates[T any](i *gorm.DB, primaryKey *schema.Field, fields []*schema.Field, rows []*T) map[string]any {
+	ctx := i.Statement.Context
+	updates := make(map[string]any, len(fields))
+
+	for _, field := range fields {
+		sql := strings.Builder{}
+		sql.WriteString("CASE ?")
+
+		vars := []any{clause.Column{Name: primaryKey.DBName}}
+
+		for _, row := range rows {
+			id, _ := primaryKey.ValueOf(ctx, reflect.ValueOf(row).Elem())
+			value, _ := field.ValueOf(ctx, reflect.ValueOf(row).Elem())
+
+			sql.WriteString(" WHEN ? THEN ?")
+			vars = append(vars, id, value)
+		}
+
+		sql.WriteString(" END")
+		updates[field.DBName] = clause.Expr{SQL: sql.String(), Vars: vars}
+	}
+
+	return updates
+}
+
+// UpdateInBatches updates columns of each of rows to that row's own values, looking rows up by
+// primary key. Each batch of batchSize rows is updated with a single statement. Returns the number
+// of rows affected, which includes rows that already had their values if the DSN sets
+// clientFoundRows (see Result).
+//
+// Example:
+//
+//	UpdateInBatches(rows, []string{"name", "position"}, 100)
+func UpdateInBatches[T any](rows []*T, columns []string, batchSize int) int64 {
+	return updateInBatches(i, rows, columns, batchSize)
+}
+
+// UpdateInBatchesD updates columns of each of rows (in debug mode) to that row's own values,
+// looking rows up by primary key. Each batch of batchSize rows is updated with a single statement.
+// Returns the number of rows affected.
+func UpdateInBatchesD[T any](rows []*T, columns []string, batchSize int) int64 {
+	return updateInBatches(i.Debug(), rows, columns, batchSize)
+}
+
 // —————————————————————————————————————————————————————————————————————————————————————————————————
 // Exec
 // —————————————————————————————————————————————————————————————————————————————————————————————————
@@ -189,7 +473,10 @@ func exec(i *gorm.DB, sql string, args ...any) int64 {
 	return res.RowsAffected
 }
 
-// Exec executes some raw SQL and returns the number of rows affected.
+// Exec executes some raw SQL and returns the number of rows affected. If the DSN sets
+// clientFoundRows (see Result) then this counts rows matched by an UPDATE rather than only those
+// that changed, and a row left unchanged by INSERT ... ON DUPLICATE KEY UPDATE counts as 1 rather
+// than 0.
 func Exec(sql string, args ...any) int64 {
 	return exec(i, sql, args...)
 }
@@ -206,6 +493,9 @@ func ExecD(sql string, args ...any) int64 {
 func query[T any](i *gorm.DB, sql string, args ...any) T {
 	var value T
 	res := i.Raw(sql, args...)
+	if isRead(sql) {
+		res = routeRead(res, false)
+	}
 	must0(res.Error)
 	must0(res.Scan(&value).Error)
 	return value
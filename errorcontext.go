@@ -0,0 +1,143 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	handleErrorContext func(ctx *ErrorContext)
+	redactErrorArgs    bool
+)
+
+// ErrorContext describes a failed database operation.
+type ErrorContext struct {
+	Err       error  // The error, translated as described in Error.
+	SQL       string // The SQL of the statement that failed, if known.
+	Args      []any  // The args of the statement that failed, if known. See Config.RedactErrorArgs.
+	Model     string // The name of the model type, if known.
+	Operation string // The name of the function in this package that was called, such as "Find".
+	Caller    string // The file:line of the code outside this package that made the call.
+}
+
+// SetContextErrorHandler sets a function to be called with an *ErrorContext if any database
+// operations fail. It takes precedence over a function set with SetErrorHandler. Set to nil to use
+// that instead.
+func SetContextErrorHandler(f func(ctx *ErrorContext)) {
+	handleErrorContext = f
+}
+
+// statementError is an error annotated with the statement that caused it.
+type statementError struct {
+	err   error
+	sql   string
+	args  []any
+	model string
+}
+
+func (self *statementError) Error() string {
+	return self.err.Error()
+}
+
+func (self *statementError) Unwrap() error {
+	return self.err
+}
+
+// annotateError wraps the error of db in a *statementError, so that the statement that caused it
+// can be passed to the contextual error handler.
+func annotateError(db *gorm.DB) {
+	if db.Error == nil || errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		return
+	}
+
+	var stmtErr *statementError
+	if errors.As(db.Error, &stmtErr) {
+		return
+	}
+
+	var model string
+	if db.Statement.Schema != nil {
+		model = db.Statement.Schema.Name
+	}
+
+	db.Error = &statementError{
+		err:   db.Error,
+		sql:   db.Statement.SQL.String(),
+		args:  append([]any{}, db.Statement.Vars...),
+		model: model,
+	}
+}
+
+// newErrorContext returns an *ErrorContext for err, using the stack to work out which function in
+// this package was called, and from where.
+func newErrorContext(err error) *ErrorContext {
+	ctx := &ErrorContext{Err: err}
+
+	var stmtErr *statementError
+	if errors.As(err, &stmtErr) {
+		ctx.SQL = stmtErr.sql
+		ctx.Args = stmtErr.args
+		ctx.Model = stmtErr.model
+
+		if redactErrorArgs {
+			ctx.Args = make([]any, len(stmtErr.args))
+			for n := range ctx.Args {
+				ctx.Args[n] = "[redacted]"
+			}
+		}
+	}
+
+	ctx.Operation, ctx.Caller = caller()
+	return ctx
+}
+
+var (
+	pkgPath        = reflect.TypeOf(Config{}).PkgPath()
+	funcNameSuffix = regexp.MustCompile(`(\[\.\.\.\])?(\.func\d+)?(\.\d+)*$`)
+)
+
+// caller returns the name of the outermost function in this package on the stack, and the file:line
+// of the code outside this package that called it.
+func caller() (operation string, location string) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	for {
+		frame, more := frames.Next()
+
+		if isPkgFunc(frame.Function) {
+			operation = funcNameSuffix.ReplaceAllString(shortFuncName(frame.Function), "")
+		} else if operation != "" && !strings.HasPrefix(frame.Function, "gorm.io/") {
+			return operation, fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+
+		if !more {
+			return operation, ""
+		}
+	}
+}
+
+// isPkgFunc returns whether the fully-qualified function name is in this package.
+func isPkgFunc(name string) bool {
+	return strings.HasPrefix(name, pkgPath+".")
+}
+
+// shortFuncName returns the fully-qualified function name without its package or receiver.
+func shortFuncName(name string) string {
+	name = strings.TrimPrefix(name, pkgPath+".")
+
+	// Remove the receiver, e.g. "(*Builder[...])."
+	if strings.HasPrefix(name, "(") {
+		if i := strings.Index(name, ")."); i != -1 {
+			name = name[i+2:]
+		}
+	}
+
+	return name
+}
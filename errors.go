@@ -1,11 +1,141 @@
 package db
 
 import (
+	"context"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
 )
 
 var handleError func(err error)
 
+// Classes of database error. Errors passed to the error handler or returned by this package are
+// translated so that errors.Is can be used to check for these, while errors.As can still be used to
+// get the underlying error (such as *mysql.MySQLError).
+var (
+	ErrDuplicateKey        = errors.New("duplicate key")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrDeadlock            = errors.New("deadlock")
+	ErrLockTimeout         = errors.New("lock wait timeout")
+	ErrConnection          = errors.New("connection error")
+	ErrDataTooLong         = errors.New("data too long")
+)
+
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+var errorClasses = map[uint16]error{
+	1022: ErrDuplicateKey,        // ER_DUP_KEY
+	1062: ErrDuplicateKey,        // ER_DUP_ENTRY
+	1586: ErrDuplicateKey,        // ER_DUP_ENTRY_WITH_KEY_NAME
+	1216: ErrForeignKeyViolation, // ER_NO_REFERENCED_ROW
+	1217: ErrForeignKeyViolation, // ER_ROW_IS_REFERENCED
+	1451: ErrForeignKeyViolation, // ER_ROW_IS_REFERENCED_2
+	1452: ErrForeignKeyViolation, // ER_NO_REFERENCED_ROW_2
+	1213: ErrDeadlock,            // ER_LOCK_DEADLOCK
+	1205: ErrLockTimeout,         // ER_LOCK_WAIT_TIMEOUT
+	3572: ErrLockTimeout,         // ER_LOCK_NOWAIT
+	1040: ErrConnection,          // ER_CON_COUNT_ERROR
+	1053: ErrConnection,          // ER_SERVER_SHUTDOWN
+	1927: ErrConnection,          // ER_CONNECTION_KILLED
+	1406: ErrDataTooLong,         // ER_DATA_TOO_LONG
+}
+
+var (
+	keyNamePattern    = regexp.MustCompile(`for key '([^']+)'`)
+	constraintPattern = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+	columnPattern     = regexp.MustCompile(`for column '([^']+)'`)
+)
+
+// Error is a database error that has been classified as one of ErrDuplicateKey,
+// ErrForeignKeyViolation, ErrDeadlock, ErrLockTimeout, ErrConnection or ErrDataTooLong.
+type Error struct {
+	Class error  // The class of error, such as ErrDuplicateKey.
+	Name  string // The key, constraint or column involved in the error, if known.
+	Err   error  // The original error.
+}
+
+func (self *Error) Error() string {
+	return self.Err.Error()
+}
+
+func (self *Error) Unwrap() []error {
+	return []error{self.Class, self.Err}
+}
+
+// translateError returns err wrapped in an *Error if it can be classified, otherwise err.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// Context errors are the caller giving up rather than a problem with the database, and
+	// context.DeadlineExceeded would otherwise be classified as a connection error as it's a net.Error.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		class, ok := errorClasses[mysqlErr.Number]
+		if !ok {
+			return err
+		}
+
+		var name string
+		switch class {
+		case ErrDuplicateKey:
+			name = submatch(keyNamePattern, mysqlErr.Message)
+		case ErrForeignKeyViolation:
+			name = submatch(constraintPattern, mysqlErr.Message)
+		case ErrDataTooLong:
+			name = submatch(columnPattern, mysqlErr.Message)
+		}
+
+		return &Error{Class: class, Name: name, Err: err}
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Class: ErrDuplicateKey, Err: err}
+
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Class: ErrForeignKeyViolation, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr) {
+		return &Error{Class: ErrConnection, Err: err}
+	}
+
+	return err
+}
+
+// submatch returns the first submatch of pattern in s, or an empty string if there isn't one.
+func submatch(pattern *regexp.Regexp, s string) string {
+	if match := pattern.FindStringSubmatch(s); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// MissingIDsError is passed to the error handler by FindByIDsStrict if any ids weren't found.
+type MissingIDsError struct {
+	IDs []any // The ids that weren't found.
+}
+
+func (self *MissingIDsError) Error() string {
+	return fmt.Sprintf("ids not found: %v", self.IDs)
+}
+
 // SetErrorHandler sets a function to be called if any database operations fail. Set to nil to use
 // the default (panic).
 func SetErrorHandler(f func(err error)) {
@@ -19,7 +149,11 @@ func must0(err any) {
 	}
 
 	var handle func(error)
-	if handleError != nil {
+	if handleErrorContext != nil {
+		handle = func(err error) {
+			handleErrorContext(newErrorContext(err))
+		}
+	} else if handleError != nil {
 		handle = handleError
 	} else {
 		handle = func(err error) {
@@ -34,7 +168,7 @@ func must0(err any) {
 		}
 
 	case error:
-		handle(e)
+		handle(translateError(e))
 
 	default:
 		panic("invalid err")
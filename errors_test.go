@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	testCases := []struct {
+		err           error
+		expectedClass error
+		expectedName  string
+	}{
+		{
+			err:           &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'john@example.com' for key 'users.idx_users_email'"},
+			expectedClass: ErrDuplicateKey,
+			expectedName:  "users.idx_users_email",
+		},
+		{
+			err:           &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`test`.`posts`, CONSTRAINT `fk_posts_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"},
+			expectedClass: ErrForeignKeyViolation,
+			expectedName:  "fk_posts_user",
+		},
+		{
+			err:           &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name' at row 1"},
+			expectedClass: ErrDataTooLong,
+			expectedName:  "name",
+		},
+		{
+			err:           fmt.Errorf("query: %w", &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}),
+			expectedClass: ErrDeadlock,
+		},
+		{
+			err:           &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"},
+			expectedClass: ErrLockTimeout,
+		},
+		{
+			err:           driver.ErrBadConn,
+			expectedClass: ErrConnection,
+		},
+		{
+			err:           mysql.ErrInvalidConn,
+			expectedClass: ErrConnection,
+		},
+		{
+			err:           &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expectedClass: ErrConnection,
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("Case%d", i+1), func(t *testing.T) {
+			err := translateError(testCase.err)
+
+			assert.ErrorIs(t, err, testCase.expectedClass)
+			assert.ErrorIs(t, err, testCase.err)
+
+			var dbErr *Error
+			assert.ErrorAs(t, err, &dbErr)
+			assert.Equal(t, testCase.expectedName, dbErr.Name)
+			assert.Equal(t, testCase.err.Error(), err.Error())
+		})
+	}
+
+	other := &mysql.MySQLError{Number: 1146, Message: "Table 'test.users' doesn't exist"}
+	assert.Same(t, other, translateError(other))
+
+	for _, err := range []error{context.Canceled, fmt.Errorf("query: %w", context.DeadlineExceeded)} {
+		assert.Same(t, err, translateError(err))
+	}
+
+	plain := errors.New("plain")
+	assert.Equal(t, plain, translateError(plain))
+	assert.Nil(t, translateError(nil))
+
+	translated := translateError(driver.ErrBadConn)
+	assert.Same(t, translated, translateError(translated))
+}
+
+func TestNewErrorContext(t *testing.T) {
+	err := translateError(&statementError{
+		err:   &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'john' for key 'users.name'"},
+		sql:   "INSERT INTO `users` (`name`) VALUES (?)",
+		args:  []any{"john"},
+		model: "User",
+	})
+
+	ctx := newErrorContext(err)
+	assert.ErrorIs(t, ctx.Err, ErrDuplicateKey)
+	assert.Equal(t, "INSERT INTO `users` (`name`) VALUES (?)", ctx.SQL)
+	assert.Equal(t, []any{"john"}, ctx.Args)
+	assert.Equal(t, "User", ctx.Model)
+
+	redactErrorArgs = true
+	defer func() { redactErrorArgs = false }()
+
+	assert.Equal(t, []any{"[redacted]"}, newErrorContext(err).Args)
+}
+
+func TestShortFuncName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: pkgPath + ".Save[...]", expected: "Save"},
+		{name: pkgPath + ".(*Builder[...]).Find", expected: "Find"},
+		{name: pkgPath + ".(*Builder[...]).Update.func1", expected: "Update"},
+		{name: pkgPath + ".Transaction.func2.1", expected: "Transaction"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("Case%d", i+1), func(t *testing.T) {
+			name := funcNameSuffix.ReplaceAllString(shortFuncName(testCase.name), "")
+			assert.Equal(t, testCase.expected, name)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	gorm_logger "gorm.io/gorm/logger"
+)
+
+// ExplainRow is a single row of the output of EXPLAIN.
+//
+// https://dev.mysql.com/doc/refman/8.0/en/explain-output.html
+type ExplainRow struct {
+	ID           int64   `gorm:"column:id"`
+	SelectType   string  `gorm:"column:select_type"`
+	Table        string  `gorm:"column:table"`
+	Partitions   string  `gorm:"column:partitions"`
+	Type         string  `gorm:"column:type"`
+	PossibleKeys string  `gorm:"column:possible_keys"`
+	Key          string  `gorm:"column:key"`
+	KeyLen       string  `gorm:"column:key_len"`
+	Ref          string  `gorm:"column:ref"`
+	Rows         int64   `gorm:"column:rows"`
+	Filtered     float64 `gorm:"column:filtered"`
+	Extra        string  `gorm:"column:Extra"`
+}
+
+// Explain returns the query plan of the query that Find would run.
+func (self *Builder[T]) Explain() []ExplainRow {
+	sql, args := self.ToSQL(func(b *Builder[T]) {
+		b.Find()
+	})
+
+	return must(explain(self.query.Session(&gorm.Session{NewDB: true}), sql, args...))
+}
+
+// explain returns the query plan of sql, which is run with args.
+func explain(db *gorm.DB, sql string, args ...any) ([]ExplainRow, error) {
+	var rows []ExplainRow
+	err := db.Raw("EXPLAIN "+sql, args...).Scan(&rows).Error
+	return rows, err
+}
+
+// isExplainable returns whether sql is a read that can be explained. Writes aren't explained as
+// they have already run by the time they're found to be slow.
+func isExplainable(sql string) bool {
+	return readPattern.MatchString(sql)
+}
+
+// The context key that holds the *gorm.Statement of a query (see trackStatement).
+type statementKey struct{}
+
+// trackStatement adds db's statement to its context so that the logger can explain the query with
+// its original SQL and args if it turns out to be slow, rather than the interpolated SQL it logs.
+func trackStatement(db *gorm.DB) {
+	if logger, ok := db.Logger.(*logger); !ok || logger.explain == nil {
+		return
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	db.Statement.Context = context.WithValue(ctx, statementKey{}, db.Statement)
+}
+
+// The longest a slow query's EXPLAIN may take.
+const explainTimeout = 10 * time.Second
+
+// Whether a slow query is being explained. Only one is explained at a time so that a burst of slow
+// queries doesn't tie up the connection pool.
+var explaining atomic.Bool
+
+// newExplainer returns a function that returns the query plan of sql, which is run with args, using
+// whatever the current instance is when it is called.
+func newExplainer() explainFunc {
+	return func(ctx context.Context, sql string, args []any) ([]ExplainRow, error) {
+		// The logger is discarded so that slow EXPLAINs don't themselves get explained.
+		return explain(i.Session(&gorm.Session{NewDB: true, Context: ctx, Logger: gorm_logger.Discard}), sql, args...)
+	}
+}
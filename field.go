@@ -0,0 +1,140 @@
+package db
+
+import "gorm.io/gorm/clause"
+
+// Field is a typed reference to a column of model T that holds values of type V. Renaming or
+// removing a struct field causes any code that refers to the old Field to fail to compile, rather
+// than failing at runtime.
+//
+// Fields are normally generated by cmd/dbcols rather than created by hand. See its documentation
+// for details.
+//
+// Examples:
+//
+//	db.B[User]().Filter(Cols.User.Email.Eq("john@example.com"))
+//	db.B[User]().Order(Cols.User.CreatedAt.Desc())
+//	db.B[User]().Update(Cols.User.Name, "John")
+type Field[T any, V any] struct {
+	name string
+}
+
+// NewField returns a new Field for the column name of model T.
+func NewField[T any, V any](name string) Field[T, V] {
+	return Field[T, V]{name: name}
+}
+
+// Name returns the name of the column.
+func (self Field[T, V]) Name() string {
+	return self.name
+}
+
+// Eq returns a condition that matches rows where the column is equal to value.
+func (self Field[T, V]) Eq(value V) Cond[T] {
+	return Cond[T]{clause.Eq{Column: self.column(), Value: value}}
+}
+
+// Neq returns a condition that matches rows where the column is not equal to value.
+func (self Field[T, V]) Neq(value V) Cond[T] {
+	return Cond[T]{clause.Neq{Column: self.column(), Value: value}}
+}
+
+// Gt returns a condition that matches rows where the column is greater than value.
+func (self Field[T, V]) Gt(value V) Cond[T] {
+	return Cond[T]{clause.Gt{Column: self.column(), Value: value}}
+}
+
+// Gte returns a condition that matches rows where the column is greater than or equal to value.
+func (self Field[T, V]) Gte(value V) Cond[T] {
+	return Cond[T]{clause.Gte{Column: self.column(), Value: value}}
+}
+
+// Lt returns a condition that matches rows where the column is less than value.
+func (self Field[T, V]) Lt(value V) Cond[T] {
+	return Cond[T]{clause.Lt{Column: self.column(), Value: value}}
+}
+
+// Lte returns a condition that matches rows where the column is less than or equal to value.
+func (self Field[T, V]) Lte(value V) Cond[T] {
+	return Cond[T]{clause.Lte{Column: self.column(), Value: value}}
+}
+
+// In returns a condition that matches rows where the column is equal to any of values. If values is
+// empty then no rows match.
+func (self Field[T, V]) In(values ...V) Cond[T] {
+	return Cond[T]{clause.IN{Column: self.column(), Values: toSlice(values)}}
+}
+
+// Between returns a condition that matches rows where the column is between low and high
+// (inclusive).
+func (self Field[T, V]) Between(low V, high V) Cond[T] {
+	return Cond[T]{clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{self.column(), low, high}}}
+}
+
+// Like returns a condition that matches rows where the column matches pattern.
+func (self Field[T, V]) Like(pattern string) Cond[T] {
+	return Cond[T]{clause.Like{Column: self.column(), Value: pattern}}
+}
+
+// IsNull returns a condition that matches rows where the column is NULL.
+func (self Field[T, V]) IsNull() Cond[T] {
+	return Cond[T]{clause.Expr{SQL: "? IS NULL", Vars: []any{self.column()}}}
+}
+
+// IsNotNull returns a condition that matches rows where the column is not NULL.
+func (self Field[T, V]) IsNotNull() Cond[T] {
+	return Cond[T]{clause.Expr{SQL: "? IS NOT NULL", Vars: []any{self.column()}}}
+}
+
+// Asc returns an ascending ordering by the column, for use with Order.
+func (self Field[T, V]) Asc() clause.OrderByColumn {
+	return clause.OrderByColumn{Column: self.column()}
+}
+
+// Desc returns a descending ordering by the column, for use with Order.
+func (self Field[T, V]) Desc() clause.OrderByColumn {
+	return clause.OrderByColumn{Column: self.column(), Desc: true}
+}
+
+func (self Field[T, V]) column() clause.Column {
+	return clause.Column{Name: self.name}
+}
+
+// columnName returns the name of the column referred to by key, which must be a string or a Field.
+func columnName(key any) string {
+	switch key := key.(type) {
+	case string:
+		return key
+	case interface{ Name() string }:
+		return key.Name()
+	default:
+		panic("invalid column")
+	}
+}
+
+// Cond is a condition on the columns of model T, for use with Filter.
+type Cond[T any] struct {
+	expr clause.Expression
+}
+
+// Or returns a condition that matches rows that match either the current condition or other.
+func (self Cond[T]) Or(other Cond[T]) Cond[T] {
+	return Cond[T]{clause.Or(self.expr, other.expr)}
+}
+
+// Not returns a condition that matches rows that don't match the current condition.
+func (self Cond[T]) Not() Cond[T] {
+	return Cond[T]{clause.Not(self.expr)}
+}
+
+// Filter adds a WHERE clause to the query for each of conds.
+//
+// Examples:
+//
+//	Filter(Cols.User.Email.Eq("john@example.com"))
+//	Filter(Cols.User.Age.Gte(18), Cols.User.Name.In("John", "Jane"))
+func (self *Builder[T]) Filter(conds ...Cond[T]) *Builder[T] {
+	for _, cond := range conds {
+		self.query = self.query.Where(cond.expr)
+	}
+	return self
+}
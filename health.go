@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var migrations []*Migration
+
+// Ping checks that the primary can be reached.
+func Ping(ctx context.Context) error {
+	db, err := i.DB()
+	if err != nil {
+		return err
+	}
+
+	return translateError(db.PingContext(ctx))
+}
+
+// HealthReport describes the state of the database.
+type HealthReport struct {
+	Primary           error           // The error from pinging the primary, if any.
+	Replicas          []ReplicaHealth // The state of each replica, in the order configured.
+	PendingMigrations []string        // The IDs of migrations that haven't been run.
+	Err               error           // The error from checking for pending migrations, if any.
+}
+
+// ReplicaHealth describes the state of a read replica.
+type ReplicaHealth struct {
+	Err    error         // The error from pinging the replica, if any.
+	Lag    time.Duration // How far behind the primary the replica is.
+	LagErr error         // The error from checking the lag (which needs the REPLICATION CLIENT privilege), if any.
+}
+
+// OK returns whether the primary and all replicas can be reached, and all migrations have been run.
+// Replication lag isn't taken into account, as what's acceptable depends on the application.
+func (self *HealthReport) OK() bool {
+	if self.Primary != nil || self.Err != nil || len(self.PendingMigrations) > 0 {
+		return false
+	}
+
+	for _, replica := range self.Replicas {
+		if replica.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Health checks the state of the database: whether the primary and replicas can be reached, how
+// far behind the primary each replica is, and whether any migrations haven't been run.
+//
+// Example:
+//
+//	http.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+//		if health := db.Health(r.Context()); !health.OK() {
+//			w.WriteHeader(http.StatusServiceUnavailable)
+//		}
+//	})
+func Health(ctx context.Context) *HealthReport {
+	health := &HealthReport{Primary: Ping(ctx)}
+
+	for _, replica := range replicas {
+		var replicaHealth ReplicaHealth
+		if err := replica.PingContext(ctx); err != nil {
+			replicaHealth.Err = translateError(err)
+		} else {
+			replicaHealth.Lag, replicaHealth.LagErr = replicationLag(ctx, replica.DB)
+		}
+		health.Replicas = append(health.Replicas, replicaHealth)
+	}
+
+	if health.Primary == nil {
+		health.PendingMigrations, health.Err = NewMigrator(i.WithContext(ctx), migrations).Pending()
+	}
+
+	return health
+}
+
+// replicationLag returns how far behind its source the replica db is.
+func replicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	// SHOW REPLICA STATUS was added in MySQL 8.0.22, replacing SHOW SLAVE STATUS.
+	status, err := queryRow(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil {
+		if status, err = queryRow(ctx, db, "SHOW SLAVE STATUS"); err != nil {
+			return 0, translateError(err)
+		}
+	}
+
+	if status == nil {
+		return 0, errors.New("not a replica")
+	}
+
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		value, ok := status[column]
+		if !ok {
+			continue
+		}
+
+		if value == nil {
+			return 0, errors.New("replication is not running")
+		}
+
+		seconds, err := strconv.Atoi(string(value))
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %q", column, value)
+		}
+
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return 0, errors.New("replication lag not reported")
+}
+
+// queryRow returns the first row returned by query as a map of column names to raw values, or nil
+// if there were no rows.
+func queryRow(ctx context.Context, db *sql.DB, query string) (map[string][]byte, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for n := range values {
+		dest[n] = &values[n]
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string][]byte, len(columns))
+	for n, column := range columns {
+		if values[n] != nil {
+			row[column] = append([]byte{}, values[n]...)
+		} else {
+			row[column] = nil
+		}
+	}
+
+	return row, nil
+}
+
+// The interval at which Close checks whether in-flight queries have finished.
+const closePollInterval = 10 * time.Millisecond
+
+var ErrClosed = errors.New("database is closing")
+
+// Whether Close has been called, after which new queries are rejected.
+var closing atomic.Bool
+
+// checkClosing adds ErrClosed to db if Close has been called, unless it's running within a
+// transaction, which is allowed to finish.
+func checkClosing(db *gorm.DB) {
+	if closing.Load() && !inTransaction(db) {
+		_ = db.AddError(ErrClosed)
+	}
+}
+
+// Close waits for in-flight queries and transactions to finish, then closes the connections to the
+// primary and any replicas. New queries and transactions fail with ErrClosed while it waits. If ctx
+// is done before they finish then the connections are closed anyway, and the error of ctx is
+// returned.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	db.Close(ctx)
+func Close(ctx context.Context) error {
+	closing.Store(true)
+
+	if stopPoolMonitor != nil {
+		stopPoolMonitor()
+		stopPoolMonitor = nil
+	}
+
+	primary, err := i.DB()
+	if err != nil {
+		return err
+	}
+
+	pools := []*sql.DB{primary}
+	for _, replica := range replicas {
+		pools = append(pools, replica.DB)
+	}
+
+	ticker := time.NewTicker(closePollInterval)
+	defer ticker.Stop()
+
+	for err == nil && inUse(pools) {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	closeReplicas(replicas)
+	replicas = nil
+
+	return errors.Join(err, primary.Close())
+}
+
+// inUse returns whether any connections from pools are in use.
+func inUse(pools []*sql.DB) bool {
+	for _, pool := range pools {
+		if pool.Stats().InUse > 0 {
+			return true
+		}
+	}
+	return false
+}
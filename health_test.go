@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestHealthReportOK(t *testing.T) {
+	assert.True(t, (&HealthReport{Replicas: []ReplicaHealth{{}}}).OK())
+	assert.False(t, (&HealthReport{Primary: ErrConnection}).OK())
+	assert.True(t, (&HealthReport{Replicas: []ReplicaHealth{{LagErr: errors.New("access denied")}}}).OK())
+	assert.False(t, (&HealthReport{Replicas: []ReplicaHealth{{}, {Err: ErrConnection}}}).OK())
+	assert.False(t, (&HealthReport{PendingMigrations: []string{"add_users"}}).OK())
+}
+
+func TestClose(t *testing.T) {
+	dryRun(t)
+
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+
+	assert.Nil(t, Close(context.Background()))
+	defer closing.Store(false)
+
+	sqlDB, err := i.DB()
+	assert.Nil(t, err)
+	assert.NotNil(t, sqlDB.Ping())
+
+	// New queries and transactions are rejected, but those already in a transaction can finish.
+	B[builderModel]().Find()
+	assert.ErrorIs(t, handled, ErrClosed)
+
+	assert.ErrorIs(t, Transaction(func(tx *gorm.DB) error { return nil }), ErrClosed)
+
+	handled = nil
+	tx := &gorm.DB{Statement: &gorm.Statement{ConnPool: fakeTx{}}}
+	B[builderModel]().Tx(tx).Find()
+	assert.Nil(t, handled)
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Chunk calls f with successive batches of at most size rows that match the query, until there are
+// no rows left or f returns an error, which is returned. Rows are fetched in order of primary key
+// using the last primary key of each batch as the starting point of the next, so any Order, Limit or
+// Offset on the builder is ignored.
+func (self *Builder[T]) Chunk(size int, f func([]*T) error) error {
+	if size < 1 {
+		panic("size must be greater than zero")
+	}
+
+	query := self.clone()
+	must0(query.Statement.Parse(new(T)))
+
+	// Clear any existing ordering and paging so batches are always contiguous.
+	delete(query.Statement.Clauses, "ORDER BY")
+	delete(query.Statement.Clauses, "LIMIT")
+
+	// Group any ORed conditions so that the keyset condition applies to all of them.
+	groupWhere(query.Statement)
+
+	primaryKey := query.Statement.Schema.PrioritizedPrimaryField
+	if primaryKey == nil {
+		panic("model has no primary key")
+	}
+
+	// The primary key of the last row of each batch is needed to fetch the next.
+	if selects := query.Statement.Selects; len(selects) > 0 && !slices.Contains(selects, "*") && !slices.Contains(selects, primaryKey.DBName) {
+		query.Statement.Selects = append(slices.Clip(selects), primaryKey.DBName)
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: primaryKey.DBName}
+
+	var last any
+	for {
+		batch := query
+		if last != nil {
+			batch = batch.Where(clause.Gt{Column: column, Value: last})
+		}
+
+		var rows []*T
+		must0(batch.Order(clause.OrderByColumn{Column: column}).Limit(size).Find(&rows).Error)
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if err := f(rows); err != nil {
+			return err
+		}
+
+		if len(rows) < size {
+			return nil
+		}
+
+		var isZero bool
+		last, isZero = primaryKey.ValueOf(query.Statement.Context, reflect.ValueOf(rows[len(rows)-1]).Elem())
+		if isZero {
+			panic("primary key of last row is zero (was it selected?)")
+		}
+	}
+}
+
+// Each calls f for each row that matches the query, streaming rows from the database one at a time
+// rather than loading them all into memory. Iteration stops at the first error returned by f, which
+// is returned.
+func (self *Builder[T]) Each(f func(*T) error) error {
+	rows := self.Rows()
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := f(rows.Row()); err != nil {
+			return err
+		}
+	}
+
+	must0(rows.Err())
+	return nil
+}
+
+// Rows returns an iterator over the rows that match the query, which streams rows from the database
+// one at a time. The iterator must be closed once it is no longer needed.
+//
+// Example:
+//
+//	rows := db.B[Model]().Rows()
+//	defer rows.Close()
+//
+//	for rows.Next() {
+//		row := rows.Row()
+//	}
+func (self *Builder[T]) Rows() *Rows[T] {
+	if _, ok := self.query.Get(sqlRecorderKey); ok {
+		panic("Rows and Each can't be used with ToSQL")
+	}
+
+	query := self.clone()
+	rows, err := query.Rows()
+	must0(err)
+
+	return &Rows[T]{
+		query: query,
+		rows:  rows,
+		err:   err,
+	}
+}
+
+// Rows is an iterator over the rows of a query.
+type Rows[T any] struct {
+	query *gorm.DB
+	rows  *sql.Rows
+	row   *T
+	err   error
+}
+
+// Next prepares the next row for reading with Row, and returns false if there are no more rows or
+// an error occurred.
+func (self *Rows[T]) Next() bool {
+	if self.err != nil || !self.rows.Next() {
+		return false
+	}
+
+	row := new(T)
+	if err := self.query.ScanRows(self.rows, row); err != nil {
+		self.err = err
+		return false
+	}
+
+	self.row = row
+	return true
+}
+
+// Row returns the current row.
+func (self *Rows[T]) Row() *T {
+	return self.row
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (self *Rows[T]) Err() error {
+	if self.rows == nil {
+		return translateError(self.err)
+	}
+	return translateError(errors.Join(self.err, self.rows.Err()))
+}
+
+// Close closes the underlying rows, stopping any further iteration. It is safe to call Close more
+// than once.
+func (self *Rows[T]) Close() error {
+	if self.rows == nil {
+		return nil
+	}
+	return self.rows.Close()
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestChunk(t *testing.T) {
+	dryRun(t)
+
+	var statements []string
+	batches := [][]*builderModel{{{ID: 1}, {ID: 2}}, {{ID: 3}}}
+
+	// Nothing is found in dry run mode, so pretend each query finds the next of batches.
+	find := func(db *gorm.DB) {
+		statements = append(statements, i.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+		if rows, ok := db.Statement.Dest.(*[]*builderModel); ok && len(statements) <= len(batches) {
+			*rows = batches[len(statements)-1]
+		}
+	}
+	assert.Nil(t, i.Callback().Query().After("gorm:query").Register("test:find", find))
+
+	var ids []uint
+	err := B[builderModel]("age > ?", 18).OrWhere("name = ?", "John").Order("name").Limit(5).Chunk(2, func(rows []*builderModel) error {
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{1, 2, 3}, ids)
+	assert.Equal(t, []string{
+		"SELECT * FROM `builder_models` WHERE age > 18 OR name = 'John' ORDER BY `builder_models`.`id` LIMIT 2",
+		"SELECT * FROM `builder_models` WHERE (age > 18 OR name = 'John') AND `builder_models`.`id` > 2 ORDER BY `builder_models`.`id` LIMIT 2",
+	}, statements)
+
+	assert.Panics(t, func() { B[builderModel]().Chunk(0, nil) })
+
+	// The primary key is needed to fetch the next batch, so it's selected if it wasn't already.
+	sql, _ := B[builderModel]().Select("name").ToSQL(func(b *Builder[builderModel]) {
+		b.Chunk(100, func([]*builderModel) error { return nil })
+	})
+	assert.Equal(t, "SELECT `name`,`id` FROM `builder_models` ORDER BY `builder_models`.`id` LIMIT ?", sql)
+}
+
+func TestRowsError(t *testing.T) {
+	dryRun(t)
+
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+
+	// Rows isn't supported in dry run mode, so this exercises the path where the query fails.
+	rows := B[builderModel]().Rows()
+	assert.NotNil(t, handled)
+
+	assert.False(t, rows.Next())
+	assert.Equal(t, handled, rows.Err())
+	assert.Nil(t, rows.Close())
+	assert.Nil(t, rows.Close())
+
+	err := B[builderModel]().Each(func(*builderModel) error {
+		t.Fatal("f should not be called")
+		return nil
+	})
+	assert.Nil(t, err)
+}
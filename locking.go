@@ -0,0 +1,54 @@
+package db
+
+import "gorm.io/gorm/clause"
+
+type LockOption string
+
+const (
+	// SkipLocked skips rows that are locked by another transaction instead of waiting for them.
+	SkipLocked LockOption = clause.LockingOptionsSkipLocked
+
+	// NoWait fails immediately if any rows are locked by another transaction instead of waiting for
+	// them.
+	NoWait LockOption = clause.LockingOptionsNoWait
+)
+
+// LockForUpdate adds a FOR UPDATE clause to the query, which locks matching rows against updates
+// and locking reads by other transactions until the current transaction ends. The builder must be
+// running within a transaction (see Tx), otherwise the query fails with ErrLockOutsideTransaction.
+//
+// Examples:
+//
+//	LockForUpdate()
+//	LockForUpdate(db.SkipLocked)
+//	LockForUpdate(db.NoWait)
+func (self *Builder[T]) LockForUpdate(options ...LockOption) *Builder[T] {
+	return self.lock(clause.LockingStrengthUpdate, options)
+}
+
+// LockForShare adds a FOR SHARE clause to the query, which locks matching rows against updates by
+// other transactions until the current transaction ends. The builder must be running within a
+// transaction (see Tx), otherwise the query fails with ErrLockOutsideTransaction.
+//
+// Examples:
+//
+//	LockForShare()
+//	LockForShare(db.SkipLocked)
+//	LockForShare(db.NoWait)
+func (self *Builder[T]) LockForShare(options ...LockOption) *Builder[T] {
+	return self.lock(clause.LockingStrengthShare, options)
+}
+
+func (self *Builder[T]) lock(strength string, options []LockOption) *Builder[T] {
+	if len(options) > 1 {
+		panic("only one lock option may be passed")
+	}
+
+	locking := clause.Locking{Strength: strength}
+	if len(options) == 1 {
+		locking.Options = string(options[0])
+	}
+
+	self.query = self.query.Clauses(locking)
+	return self
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeTx is a connection pool that looks like a transaction, for building queries with Tx in dry
+// run mode.
+type fakeTx struct {
+	gorm.ConnPool
+}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func TestLocking(t *testing.T) {
+	dryRun(t)
+
+	tx := &gorm.DB{Statement: &gorm.Statement{ConnPool: fakeTx{}}}
+
+	testCases := []struct {
+		builder  *Builder[builderModel]
+		expected string
+	}{
+		{
+			builder:  B[builderModel]("id = ?", 1).Tx(tx).LockForUpdate(),
+			expected: "SELECT * FROM `builder_models` WHERE id = 1 FOR UPDATE",
+		},
+		{
+			builder:  B[builderModel]("id = ?", 1).Tx(tx).LockForUpdate(SkipLocked),
+			expected: "SELECT * FROM `builder_models` WHERE id = 1 FOR UPDATE SKIP LOCKED",
+		},
+		{
+			builder:  B[builderModel]("id = ?", 1).Tx(tx).LockForShare(NoWait),
+			expected: "SELECT * FROM `builder_models` WHERE id = 1 FOR SHARE NOWAIT",
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, findSQL(testCase.builder))
+	}
+}
+
+func TestLockOutsideTransaction(t *testing.T) {
+	dryRun(t)
+
+	var handled error
+	SetErrorHandler(func(err error) { handled = err })
+	defer SetErrorHandler(nil)
+
+	B[builderModel]("id = ?", 1).LockForUpdate().Find()
+	assert.ErrorIs(t, handled, ErrLockOutsideTransaction)
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"gorm.io/gorm"
 	gorm_logger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
 )
 
 // newLogger returns a version of gorm's logger that shows loglines in a much more compact fashion.
-func newLogger(writer gorm_logger.Writer, config gorm_logger.Config) gorm_logger.Interface {
+// If explain is not nil then it is used to attach the query plan to the logline of slow queries.
+func newLogger(writer gorm_logger.Writer, config gorm_logger.Config, explain explainFunc) gorm_logger.Interface {
 	var (
 		infoStr      = "%s [info] "
 		warnStr      = "%s [warn] "
@@ -18,6 +22,8 @@ func newLogger(writer gorm_logger.Writer, config gorm_logger.Config) gorm_logger
 		traceStr     = "[%.0f ms] [%v] %s"
 		traceWarnStr = "[%.0f ms] [%v] (%s) %s"
 		traceErrStr  = "[%.0f ms] [%v] (%s) %s"
+		planStr      = "  [plan] %s"
+		explainStr   = "  [plan] table=%s type=%s key=%s rows=%d"
 	)
 
 	if config.Colorful {
@@ -27,6 +33,8 @@ func newLogger(writer gorm_logger.Writer, config gorm_logger.Config) gorm_logger
 		traceStr = gorm_logger.Yellow + "[%.0f ms] " + gorm_logger.BlueBold + "[%v]" + gorm_logger.Reset + " %s"
 		traceWarnStr = gorm_logger.Yellow + "[%.0f ms] " + gorm_logger.BlueBold + "[%v]" + gorm_logger.RedBold + " %s" + gorm_logger.Reset + gorm_logger.Cyan + " %s" + gorm_logger.Reset
 		traceErrStr = gorm_logger.Yellow + "[%.0f ms] " + gorm_logger.BlueBold + "[%v]" + gorm_logger.RedBold + " %s" + gorm_logger.Reset + "\n%s"
+		planStr = gorm_logger.Cyan + "  [plan]" + gorm_logger.Reset + " %s"
+		explainStr = gorm_logger.Cyan + "  [plan]" + gorm_logger.Reset + " table=%s type=%s key=%s rows=%d"
 	}
 
 	return &logger{
@@ -38,9 +46,14 @@ func newLogger(writer gorm_logger.Writer, config gorm_logger.Config) gorm_logger
 		traceStr:     traceStr,
 		traceWarnStr: traceWarnStr,
 		traceErrStr:  traceErrStr,
+		planStr:      planStr,
+		explainStr:   explainStr,
+		explain:      explain,
 	}
 }
 
+type explainFunc func(ctx context.Context, sql string, args []any) ([]ExplainRow, error)
+
 type logger struct {
 	gorm_logger.Writer
 	gorm_logger.Config
@@ -51,6 +64,10 @@ type logger struct {
 	traceStr     string
 	traceErrStr  string
 	traceWarnStr string
+	planStr      string
+	explainStr   string
+
+	explain explainFunc
 }
 
 func (self *logger) LogMode(level gorm_logger.LogLevel) gorm_logger.Interface {
@@ -61,19 +78,19 @@ func (self *logger) LogMode(level gorm_logger.LogLevel) gorm_logger.Interface {
 
 func (self logger) Info(ctx context.Context, msg string, args ...any) {
 	if self.LogLevel >= gorm_logger.Info {
-		self.Printf(self.infoStr+msg, args...)
+		self.Printf(self.infoStr+msg, append([]any{utils.FileWithLineNum()}, args...)...)
 	}
 }
 
 func (self logger) Warn(ctx context.Context, msg string, args ...any) {
 	if self.LogLevel >= gorm_logger.Warn {
-		self.Printf(self.warnStr+msg, args...)
+		self.Printf(self.warnStr+msg, append([]any{utils.FileWithLineNum()}, args...)...)
 	}
 }
 
 func (self logger) Error(ctx context.Context, msg string, args ...any) {
 	if self.LogLevel >= gorm_logger.Error {
-		self.Printf(self.errStr+msg, args...)
+		self.Printf(self.errStr+msg, append([]any{utils.FileWithLineNum()}, args...)...)
 	}
 }
 
@@ -106,9 +123,63 @@ func (self logger) Trace(ctx context.Context, begin time.Time, f func() (string,
 	case isSlow && self.LogLevel >= gorm_logger.Warn:
 		sql, rows := f()
 		self.Printf(self.traceWarnStr, ms, r(rows), fmt.Sprintf(">= %v", self.SlowThreshold), sql)
+		self.explainSlow(ctx)
 
 	case self.LogLevel == gorm_logger.Info:
 		sql, rows := f()
 		self.Printf(self.traceStr, ms, r(rows), sql)
 	}
 }
+
+// explainSlow prints the query plan of the slow query whose statement is in ctx (see
+// trackStatement), if explaining is enabled and the query is a read. The plan is looked up in the
+// background so that the caller isn't held up, nor blocked waiting for a second connection while
+// holding one in a transaction.
+func (self logger) explainSlow(ctx context.Context) {
+	stmt, ok := ctx.Value(statementKey{}).(*gorm.Statement)
+	if self.explain == nil || !ok {
+		return
+	}
+
+	// The statement is reset by gorm once it has been logged, so its SQL and args are copied here.
+	sql := stmt.SQL.String()
+	args := append([]any{}, stmt.Vars...)
+
+	if !isExplainable(sql) || !explaining.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer explaining.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), explainTimeout)
+		defer cancel()
+
+		self.printPlan(ctx, sql, args)
+	}()
+}
+
+// printPlan prints the query plan of sql, which is run with args.
+func (self logger) printPlan(ctx context.Context, sql string, args []any) {
+	plan, err := self.explain(ctx, sql, args)
+	if err != nil {
+		self.Printf(self.errStr+"explain: %s", utils.FileWithLineNum(), err)
+		return
+	}
+
+	v := func(s string) string {
+		if s == "" {
+			return "-"
+		} else {
+			return s
+		}
+	}
+
+	// The plan is printed in one go so that it isn't interleaved with other loglines.
+	lines := []string{fmt.Sprintf(self.planStr, sql)}
+	for _, row := range plan {
+		lines = append(lines, fmt.Sprintf(self.explainStr, v(row.Table), v(row.Type), v(row.Key), row.Rows))
+	}
+
+	self.Printf("%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gorm_logger "gorm.io/gorm/logger"
+)
+
+type bufferWriter struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (self *bufferWriter) Printf(format string, args ...any) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.lines = append(self.lines, fmt.Sprintf(format, args...))
+}
+
+func (self *bufferWriter) Lines() []string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return slices.Clone(self.lines)
+}
+
+func TestLoggerExplainsSlowQueries(t *testing.T) {
+	explain := func(ctx context.Context, sql string, args []any) ([]ExplainRow, error) {
+		assert.Equal(t, "SELECT * FROM users WHERE name = ?", sql)
+		assert.Equal(t, []any{`O\'Brien`}, args)
+		return []ExplainRow{{Table: "users", Type: "ALL", Rows: 1000}}, nil
+	}
+
+	trace := func(sql string, args ...any) []string {
+		writer := &bufferWriter{}
+
+		l := newLogger(writer, gorm_logger.Config{
+			LogLevel:      gorm_logger.Warn,
+			SlowThreshold: time.Millisecond,
+		}, explain)
+
+		stmt := &gorm.Statement{Vars: args}
+		stmt.SQL.WriteString(sql)
+		ctx := context.WithValue(context.Background(), statementKey{}, stmt)
+
+		l.Trace(ctx, time.Now().Add(-time.Second), func() (string, int64) {
+			return "(interpolated)", 1
+		}, nil)
+
+		// gorm resets the statement once it has been logged.
+		stmt.SQL.Reset()
+		stmt.Vars = nil
+
+		assert.Eventually(t, func() bool { return !explaining.Load() }, time.Second, time.Millisecond)
+		return writer.Lines()
+	}
+
+	lines := trace("SELECT * FROM users WHERE name = ?", `O\'Brien`)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "  [plan] SELECT * FROM users WHERE name = ?\n  [plan] table=users type=ALL key=- rows=1000", lines[1])
+	}
+
+	assert.Len(t, trace("UPDATE users SET name = ?", "John"), 1)
+}
+
+func TestIsExplainable(t *testing.T) {
+	assert.True(t, isExplainable("SELECT 1"))
+	assert.True(t, isExplainable("  with x AS (SELECT 1) SELECT * FROM x"))
+	assert.False(t, isExplainable("UPDATE users SET name = 'John'"))
+	assert.False(t, isExplainable("EXPLAIN SELECT 1"))
+	assert.False(t, isExplainable("CREATE TABLE users (id int)"))
+	assert.False(t, isExplainable(""))
+}
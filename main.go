@@ -1,9 +1,13 @@
 package db
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	gorm_mysql "gorm.io/driver/mysql"
@@ -18,10 +22,33 @@ const UnknownDatabaseError = 1049
 
 // Init initialises the database.
 func Init(config *Config) error {
+	return translateError(initialise(config))
+}
+
+func initialise(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
 	if config.ErrorHandler != nil {
 		SetErrorHandler(config.ErrorHandler)
 	}
 
+	if config.ContextErrorHandler != nil {
+		SetContextErrorHandler(config.ContextErrorHandler)
+	}
+
+	redactErrorArgs = config.RedactErrorArgs
+
+	if config.Retry != nil {
+		SetRetryPolicy(config.Retry)
+	}
+
+	var explain explainFunc
+	if config.ExplainSlow {
+		explain = newExplainer()
+	}
+
 	// https://gorm.io/docs/gorm_config.html
 	gormConfig := gorm.Config{
 		AllowGlobalUpdate: true,
@@ -35,6 +62,7 @@ func Init(config *Config) error {
 			SlowThreshold:             config.SlowThreshold,
 			Colorful:                  config.Colour,
 		},
+		explain,
 	)
 
 	if config.Debug {
@@ -43,11 +71,15 @@ func Init(config *Config) error {
 	}
 
 	var err error
-	i, err = gorm.Open(gorm_mysql.Open(config.PrimaryDSN()), &gormConfig)
+	i, err = open(config, &gormConfig)
 
 	var needsSeed bool
 
 	if err == nil && config.Fresh {
+		if err := checkFresh(config, i); err != nil {
+			return err
+		}
+
 		if err := dropDatabase(config, &gormConfig); err != nil {
 			return err
 		}
@@ -77,6 +109,37 @@ func Init(config *Config) error {
 		needsSeed = true
 	}
 
+	if err := registerCallbacks(i); err != nil {
+		return err
+	}
+	closing.Store(false)
+
+	sqlDB, err := i.DB()
+	if err != nil {
+		return err
+	}
+	configurePool(sqlDB, config)
+
+	replicaDSNs := make([]string, len(config.Replicas))
+	for n := range config.Replicas {
+		if replicaDSNs[n], err = config.ReplicaDSN(n); err != nil {
+			return err
+		}
+	}
+
+	closeReplicas(replicas)
+	if replicas, err = openReplicas(replicaDSNs); err != nil {
+		return err
+	}
+	for _, replica := range replicas {
+		configurePool(replica.DB, config)
+	}
+	replicaSelection = config.ReplicaSelection
+
+	startPoolMonitor(i, config.PoolWaitThreshold)
+
+	migrations = config.Migrations
+
 	if err := migrate(config); err != nil {
 		return err
 	}
@@ -88,13 +151,100 @@ func Init(config *Config) error {
 	return nil
 }
 
+// The default delay before the first attempt to connect is retried, and the maximum delay between
+// attempts. See Config.StartupTimeout.
+const (
+	defaultStartupBackoff = 500 * time.Millisecond
+	maxStartupBackoff     = 5 * time.Second
+)
+
+// open connects to the database, retrying connection errors until config.StartupTimeout has passed
+// so that the database has time to start, or config.Context is done. Other errors, such as the
+// database not existing, are returned immediately.
+func open(config *Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	policy := &RetryPolicy{Backoff: config.StartupBackoff, MaxBackoff: maxStartupBackoff}
+	if policy.Backoff == 0 {
+		policy.Backoff = defaultStartupBackoff
+	}
+
+	ctx := config.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deadline := time.Now().Add(config.StartupTimeout)
+
+	for attempt := 1; ; attempt++ {
+		db, err := gorm.Open(gorm_mysql.Open(config.PrimaryDSN()), gormConfig)
+		if err == nil || !errors.Is(translateError(err), ErrConnection) {
+			return db, err
+		}
+
+		delay := policy.delay(attempt)
+		if time.Now().Add(delay).After(deadline) {
+			return nil, err
+		}
+
+		gormConfig.Logger.Warn(ctx, "unable to connect to the database (attempt %d), retrying in %s: %s", attempt, delay.Round(time.Millisecond), err)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ErrDestructiveNotAllowed is returned by Init if Config.Fresh is set but the database isn't safe to
+// drop.
+var ErrDestructiveNotAllowed = errors.New("refusing to drop database")
+
+// The default pattern that the database name must match for Config.Fresh to drop it.
+const defaultFreshAllowedNames = `_test$`
+
+// checkFresh returns an error if the database that db is connected to shouldn't be dropped: either
+// its name doesn't match config.FreshAllowedNames (and config.AllowDestructive isn't set), or
+// config.SentinelTable has any rows.
+func checkFresh(config *Config, db *gorm.DB) error {
+	pattern := config.FreshAllowedNames
+	if pattern == "" {
+		pattern = defaultFreshAllowedNames
+	}
+
+	if !config.AllowDestructive && !regexp.MustCompile(pattern).MatchString(config.Name) {
+		return fmt.Errorf("%w %q: name doesn't match %q (set AllowDestructive to override)", ErrDestructiveNotAllowed, config.Name, pattern)
+	}
+
+	if config.SentinelTable == "" || !db.Migrator().HasTable(config.SentinelTable) {
+		return nil
+	}
+
+	var exists bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM " + quoteIdentifier(config.SentinelTable) + ")").Scan(&exists).Error; err != nil {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("%w %q: sentinel table %q has rows", ErrDestructiveNotAllowed, config.Name, config.SentinelTable)
+	}
+
+	return nil
+}
+
 func createDatabase(config *Config, gormConfig *gorm.Config) error {
 	db, err := gorm.Open(gorm_mysql.Open(config.FallbackDSN()), gormConfig)
 	if err != nil {
 		return err
 	}
 
-	return db.Exec("CREATE DATABASE " + config.Name).Error
+	sql := "CREATE DATABASE " + quoteIdentifier(config.Name)
+
+	if config.CharSet != "" {
+		sql += " CHARACTER SET " + config.CharSet
+	}
+
+	if config.Collation != "" {
+		sql += " COLLATE " + config.Collation
+	}
+
+	return db.Exec(sql).Error
 }
 
 func dropDatabase(config *Config, gormConfig *gorm.Config) error {
@@ -103,7 +253,7 @@ func dropDatabase(config *Config, gormConfig *gorm.Config) error {
 		return err
 	}
 
-	return db.Exec("DROP DATABASE " + config.Name).Error
+	return db.Exec("DROP DATABASE " + quoteIdentifier(config.Name)).Error
 }
 
 func migrate(config *Config) error {
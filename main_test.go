@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gorm_logger "gorm.io/gorm/logger"
+)
+
+func TestOpenRetriesConnectionErrors(t *testing.T) {
+	writer := &bufferWriter{}
+
+	config := &Config{
+		User:           "user",
+		Host:           "127.0.0.1:1",
+		Name:           "test",
+		StartupTimeout: 200 * time.Millisecond,
+		StartupBackoff: 20 * time.Millisecond,
+	}
+
+	gormConfig := &gorm.Config{
+		Logger: newLogger(writer, gorm_logger.Config{LogLevel: gorm_logger.Warn}, nil),
+	}
+
+	_, err := open(config, gormConfig)
+	assert.ErrorIs(t, translateError(err), ErrConnection)
+	assert.Contains(t, strings.Join(writer.lines, "\n"), "unable to connect to the database (attempt 1)")
+
+	writer.lines = nil
+	config.StartupTimeout = 0
+
+	_, err = open(config, gormConfig)
+	assert.ErrorIs(t, translateError(err), ErrConnection)
+	assert.NotContains(t, strings.Join(writer.lines, "\n"), "unable to connect to the database")
+
+	// Waiting for the database stops when the context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	config.Context = ctx
+	config.StartupTimeout = time.Hour
+	config.StartupBackoff = time.Hour
+
+	_, err = open(config, gormConfig)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCheckFresh(t *testing.T) {
+	dryRun(t)
+
+	testCases := []struct {
+		config  *Config
+		allowed bool
+	}{
+		{config: &Config{Name: "blog_test"}, allowed: true},
+		{config: &Config{Name: "blog"}, allowed: false},
+		{config: &Config{Name: "blog_test_backup"}, allowed: false},
+		{config: &Config{Name: "blog", AllowDestructive: true}, allowed: true},
+		{config: &Config{Name: "ci_blog", FreshAllowedNames: "^ci_"}, allowed: true},
+		{config: &Config{Name: "blog_test", FreshAllowedNames: "^ci_"}, allowed: false},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("Case%d", i+1), func(t *testing.T) {
+			err := checkFresh(testCase.config, Instance())
+			if testCase.allowed {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrDestructiveNotAllowed)
+			}
+		})
+	}
+}
@@ -112,6 +112,32 @@ func (self *Migrator) Migrate(autoMigrate MigrateFunc) error {
 	)
 }
 
+// Pending returns the IDs of migrations that haven't been run (or skipped when the schema was
+// first created).
+func (self *Migrator) Pending() ([]string, error) {
+	var pending []string
+
+	if !self.db.Migrator().HasTable(self.tableName) {
+		for _, migration := range self.migrations {
+			pending = append(pending, migration.ID)
+		}
+		return pending, nil
+	}
+
+	for _, migration := range self.migrations {
+		ran, err := self.migrationAlreadyRan(migration)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ran {
+			pending = append(pending, migration.ID)
+		}
+	}
+
+	return pending, nil
+}
+
 func (self *Migrator) runMigrationType(kind MigrationType) error {
 	for _, migration := range self.migrations {
 		if migration.Type == kind {
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page is a single page of rows returned by Paginate.
+type Page[T any] struct {
+	Items   []*T  // The rows on this page.
+	Page    int   // The page number, starting from 1.
+	PerPage int   // The maximum number of rows per page.
+	Total   int64 // The total number of rows that match the query.
+	Pages   int   // The total number of pages.
+}
+
+// CursorPage is a single page of rows returned by After.
+type CursorPage[T any] struct {
+	Items []*T   // The rows on this page.
+	Next  string // The cursor for the next page, or an empty string if this is the last page.
+}
+
+// Paginate returns the rows on page number page (starting from 1), with at most perPage rows per
+// page, along with the total number of rows and pages.
+func (self *Builder[T]) Paginate(page int, perPage int) *Page[T] {
+	if perPage < 1 {
+		panic("perPage must be greater than zero")
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	// Any existing Limit or Offset would apply to the count, which is a single row.
+	counter := self.with(self.clone())
+	delete(counter.query.Statement.Clauses, "LIMIT")
+
+	total := counter.Count()
+	rows := self.with(self.clone().Limit(perPage).Offset((page - 1) * perPage)).Find()
+
+	return &Page[T]{
+		Items:   rows,
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+		Pages:   int((total + int64(perPage) - 1) / int64(perPage)),
+	}
+}
+
+// After returns at most limit rows that come after cursor when ordered by columns, along with a
+// cursor for the next page. Pass an empty cursor to fetch the first page. If no columns are passed
+// then rows are ordered by id.
+//
+// Columns may be suffixed with ASC or DESC like in Order. The final column should be unique, or
+// rows that share the same values may be skipped. Any Order, Limit or Offset on the builder is
+// ignored.
+//
+// Examples:
+//
+//	After("", 50)
+//	After(cursor, 50, "created_at DESC", "id DESC")
+func (self *Builder[T]) After(cursor string, limit int, columns ...string) *CursorPage[T] {
+	if limit < 1 {
+		panic("limit must be greater than zero")
+	}
+
+	if len(columns) == 0 {
+		columns = []string{"id"}
+	}
+
+	query := self.clone()
+	must0(query.Statement.Parse(new(T)))
+
+	// Clear any existing ordering and paging, which would conflict with the keyset.
+	delete(query.Statement.Clauses, "ORDER BY")
+	delete(query.Statement.Clauses, "LIMIT")
+
+	// Group any ORed conditions so that the keyset condition applies to all of them.
+	groupWhere(query.Statement)
+
+	keys := must(parseKeysetColumns(query.Statement.Schema, columns))
+
+	if cursor != "" {
+		values := must(decodeCursor(cursor, keys))
+		query = query.Where(keysetCondition(keys, values))
+	}
+
+	for _, key := range keys {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: key.field.DBName},
+			Desc:   key.desc,
+		})
+	}
+
+	rows := self.with(query.Limit(limit + 1)).Find()
+
+	page := &CursorPage[T]{Items: rows}
+
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		page.Next = must(encodeCursor(query.Statement.Context, keys, page.Items[limit-1]))
+	}
+
+	return page
+}
+
+type keysetColumn struct {
+	field *schema.Field
+	desc  bool
+}
+
+// parseKeysetColumns parses columns of the form "name [ASC|DESC]" into fields of s.
+func parseKeysetColumns(s *schema.Schema, columns []string) ([]keysetColumn, error) {
+	keys := make([]keysetColumn, 0, len(columns))
+
+	for _, column := range columns {
+		parts := strings.Fields(column)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid keyset column: %q", column)
+		}
+
+		var desc bool
+		if len(parts) == 2 {
+			switch strings.ToUpper(parts[1]) {
+			case "ASC":
+			case "DESC":
+				desc = true
+			default:
+				return nil, fmt.Errorf("invalid keyset column: %q", column)
+			}
+		}
+
+		field := s.LookUpField(parts[0])
+		if field == nil {
+			return nil, fmt.Errorf("unknown keyset column: %q", parts[0])
+		}
+
+		keys = append(keys, keysetColumn{field: field, desc: desc})
+	}
+
+	return keys, nil
+}
+
+// keysetCondition returns a condition that matches rows that come after values when ordered by
+// keys. For keys (a, b) this is equivalent to "a > ? OR (a = ? AND b > ?)".
+func keysetCondition(keys []keysetColumn, values []any) clause.Expression {
+	var or []clause.Expression
+
+	for n, key := range keys {
+		var and []clause.Expression
+
+		for j := 0; j < n; j++ {
+			and = append(and, clause.Eq{Column: clause.Column{Name: keys[j].field.DBName}, Value: values[j]})
+		}
+
+		column := clause.Column{Name: key.field.DBName}
+		if key.desc {
+			and = append(and, clause.Lt{Column: column, Value: values[n]})
+		} else {
+			and = append(and, clause.Gt{Column: column, Value: values[n]})
+		}
+
+		or = append(or, clause.And(and...))
+	}
+
+	return clause.Or(or...)
+}
+
+// encodeCursor encodes the values of keys in row as an opaque string.
+func encodeCursor(ctx context.Context, keys []keysetColumn, row any) (string, error) {
+	value := reflect.Indirect(reflect.ValueOf(row))
+
+	values := make([]any, len(keys))
+	for n, key := range keys {
+		values[n], _ = key.field.ValueOf(ctx, value)
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor decodes a cursor created by encodeCursor into values of the same types as the
+// fields of keys.
+func decodeCursor(cursor string, keys []keysetColumn) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil || len(raw) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, len(keys))
+	for n, key := range keys {
+		value := reflect.New(key.field.FieldType)
+		if err := json.Unmarshal(raw[n], value.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[n] = value.Elem().Interface()
+	}
+
+	return values, nil
+}
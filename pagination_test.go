@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type cursorModel struct {
+	ID        uint
+	Name      string
+	CreatedAt time.Time
+}
+
+func TestCursor(t *testing.T) {
+	s, err := schema.Parse(&cursorModel{}, &sync.Map{}, schema.NamingStrategy{})
+	assert.Nil(t, err)
+
+	keys, err := parseKeysetColumns(s, []string{"created_at DESC", "name", "id asc"})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false, false}, []bool{keys[0].desc, keys[1].desc, keys[2].desc})
+
+	row := &cursorModel{
+		ID:        18446744073709551,
+		Name:      "John",
+		CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 123, time.UTC),
+	}
+
+	cursor, err := encodeCursor(context.Background(), keys, row)
+	assert.Nil(t, err)
+
+	values, err := decodeCursor(cursor, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, []any{row.CreatedAt, row.Name, row.ID}, values)
+
+	_, err = decodeCursor("not a cursor", keys)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = decodeCursor(cursor, keys[:1])
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = parseKeysetColumns(s, []string{"missing"})
+	assert.NotNil(t, err)
+
+	_, err = parseKeysetColumns(s, []string{"id sideways"})
+	assert.NotNil(t, err)
+}
+
+func TestAfter(t *testing.T) {
+	dryRun(t)
+
+	builder := B[cursorModel]("name = ?", "John").Order("name").Offset(20)
+
+	sql, args := builder.ToSQL(func(b *Builder[cursorModel]) {
+		b.After("", 10, "id")
+	})
+	assert.Equal(t, "SELECT * FROM `cursor_models` WHERE name = ? ORDER BY `id` LIMIT ?", sql)
+	assert.Equal(t, []any{"John", 11}, args)
+
+	s, err := schema.Parse(&cursorModel{}, &sync.Map{}, schema.NamingStrategy{})
+	assert.Nil(t, err)
+
+	keys, err := parseKeysetColumns(s, []string{"created_at DESC", "id"})
+	assert.Nil(t, err)
+
+	cursor, err := encodeCursor(context.Background(), keys, &cursorModel{ID: 5})
+	assert.Nil(t, err)
+
+	sql, _ = builder.ToSQL(func(b *Builder[cursorModel]) {
+		b.After(cursor, 10, "created_at DESC", "id")
+	})
+	expected := "SELECT * FROM `cursor_models` WHERE name = ? AND (`created_at` < ? OR (`created_at` = ? AND `id` > ?)) ORDER BY `created_at` DESC,`id` LIMIT ?"
+	assert.Equal(t, expected, sql)
+
+	// The keyset condition must apply to all ORed conditions, or the cursor wouldn't move forward.
+	sql, _ = builder.OrWhere("name = ?", "Jane").ToSQL(func(b *Builder[cursorModel]) {
+		b.After(cursor, 10, "created_at DESC", "id")
+	})
+	expected = "SELECT * FROM `cursor_models` WHERE (name = ? OR name = ?) AND (`created_at` < ? OR (`created_at` = ? AND `id` > ?)) ORDER BY `created_at` DESC,`id` LIMIT ?"
+	assert.Equal(t, expected, sql)
+}
+
+func TestPaginate(t *testing.T) {
+	dryRun(t)
+
+	var statements []string
+	assert.Nil(t, i.Callback().Query().After("*").Register("test:record", func(db *gorm.DB) {
+		statements = append(statements, i.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	}))
+
+	B[cursorModel]("name = ?", "John").Limit(5).Offset(20).Paginate(3, 10)
+
+	assert.Equal(t, []string{
+		"SELECT count(*) FROM `cursor_models` WHERE name = 'John'",
+		"SELECT * FROM `cursor_models` WHERE name = 'John' LIMIT 10 OFFSET 20",
+	}, statements)
+}
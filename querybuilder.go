@@ -1,6 +1,11 @@
 package db
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+
+	gorm_logger "gorm.io/gorm/logger"
+)
 
 // QueryBuilder allows building up a query string piecemeal while still providing the safety of
 // prepared statements.
@@ -45,6 +50,18 @@ func (self *QueryBuilder) Args() []any {
 	return self.args
 }
 
+// SQL returns the query with the args interpolated into it, for debugging and tests. The result
+// must never be executed, as the interpolation is not safe against SQL injection.
+func (self *QueryBuilder) SQL() string {
+	return gorm_logger.ExplainSQL(self.Query(), nil, `'`, self.args...)
+}
+
+// Pretty returns the query with the args interpolated into it like SQL, formatted with each clause
+// on a separate line and each AND or OR condition on a separate indented line.
+func (self *QueryBuilder) Pretty() string {
+	return prettySQL(self.SQL())
+}
+
 func (self *QueryBuilder) where(operator string, condition string, args ...any) {
 	if !self.hasWhere {
 		self.query = append(self.query, "WHERE")
@@ -56,3 +73,92 @@ func (self *QueryBuilder) where(operator string, condition string, args ...any)
 	self.query = append(self.query, condition)
 	self.args = append(self.args, args...)
 }
+
+// Keywords that start a new line when pretty-printing, and keywords that don't if they follow one
+// of them.
+var (
+	clauseKeywords = map[string]bool{
+		"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "ORDER": true, "HAVING": true,
+		"LIMIT": true, "OFFSET": true, "UNION": true, "JOIN": true, "LEFT": true, "RIGHT": true,
+		"INNER": true, "CROSS": true, "SET": true, "VALUES": true,
+	}
+	conditionKeywords = map[string]bool{"AND": true, "OR": true}
+	joinPrefixes      = map[string]bool{"LEFT": true, "RIGHT": true, "INNER": true, "CROSS": true, "OUTER": true}
+)
+
+// prettySQL formats sql with each top-level clause on a separate line and each top-level AND or OR
+// condition on a separate indented line. Quoted strings and parenthesised expressions are left
+// untouched.
+func prettySQL(sql string) string {
+	var (
+		builder   strings.Builder
+		previous  string
+		inBetween bool
+	)
+
+	for _, token := range tokenizeSQL(sql) {
+		keyword := strings.ToUpper(token)
+
+		switch {
+		case builder.Len() == 0:
+		case conditionKeywords[keyword] && !(keyword == "AND" && inBetween):
+			builder.WriteString("\n    ")
+		case clauseKeywords[keyword] && !(keyword == "JOIN" && joinPrefixes[previous]):
+			builder.WriteString("\n")
+		default:
+			builder.WriteString(" ")
+		}
+
+		switch keyword {
+		case "BETWEEN":
+			inBetween = true
+		case "AND":
+			inBetween = false
+		}
+
+		builder.WriteString(token)
+		previous = keyword
+	}
+
+	return builder.String()
+}
+
+// tokenizeSQL splits sql into whitespace-separated tokens, keeping quoted strings and parenthesised
+// expressions as part of a single token.
+func tokenizeSQL(sql string) []string {
+	var (
+		tokens []string
+		token  strings.Builder
+		quote  rune
+		depth  int
+	)
+
+	for _, r := range sql {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case unicode.IsSpace(r) && depth == 0:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+			continue
+		}
+
+		token.WriteRune(r)
+	}
+
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+
+	return tokens
+}
@@ -61,3 +61,28 @@ func TestQueryBuilder(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryBuilderSQL(t *testing.T) {
+	q := Q("SELECT name, COUNT(*) FROM users LEFT JOIN posts ON posts.user_id = users.id")
+	q.And("users.name = ?", "O'Brien")
+	q.And("users.age BETWEEN ? AND ?", 18, 30)
+	q.Or("users.id IN (SELECT user_id FROM admins WHERE level > ?)", 2)
+	q.Append("GROUP BY name ORDER BY name LIMIT ?", 10)
+
+	expectedSQL := "SELECT name, COUNT(*) FROM users LEFT JOIN posts ON posts.user_id = users.id " +
+		"WHERE users.name = 'O''Brien' AND users.age BETWEEN 18 AND 30 " +
+		"OR users.id IN (SELECT user_id FROM admins WHERE level > 2) GROUP BY name ORDER BY name LIMIT 10"
+
+	expectedPretty := `SELECT name, COUNT(*)
+FROM users
+LEFT JOIN posts ON posts.user_id = users.id
+WHERE users.name = 'O''Brien'
+    AND users.age BETWEEN 18 AND 30
+    OR users.id IN (SELECT user_id FROM admins WHERE level > 2)
+GROUP BY name
+ORDER BY name
+LIMIT 10`
+
+	assert.Equal(t, expectedSQL, q.SQL())
+	assert.Equal(t, expectedPretty, q.Pretty())
+}
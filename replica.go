@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReplicaSelection is a strategy for choosing which replica to send a read to.
+type ReplicaSelection int
+
+const (
+	RoundRobin   ReplicaSelection = iota // Send reads to each replica in turn.
+	LeastLatency                         // Send most reads to the replica that has been responding fastest.
+)
+
+var (
+	replicas         []*replica
+	replicaSelection ReplicaSelection
+	nextReplica      atomic.Uint64
+)
+
+// replica is a read replica. It implements gorm.ConnPool so that it can be swapped in for the
+// primary's connection pool, recording how long each query takes to respond.
+type replica struct {
+	*sql.DB
+	dsn     string
+	latency atomic.Int64 // A moving average of the response time in nanoseconds.
+}
+
+func (self *replica) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := self.DB.QueryContext(ctx, query, args...)
+	self.observe(time.Since(start), err)
+	return rows, err
+}
+
+func (self *replica) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := self.DB.QueryRowContext(ctx, query, args...)
+	self.observe(time.Since(start), row.Err())
+	return row
+}
+
+// The response time recorded for a query that fails to connect, so that an unreachable replica is
+// avoided by LeastLatency.
+const replicaErrorPenalty = 5 * time.Second
+
+// observe records the response time of a query that took elapsed and failed with err, if any.
+// Connection errors are recorded as replicaErrorPenalty, and other errors aren't recorded as they
+// don't reflect how fast the replica is.
+func (self *replica) observe(elapsed time.Duration, err error) {
+	if err != nil {
+		if !errors.Is(translateError(err), ErrConnection) {
+			return
+		}
+		elapsed = max(elapsed, replicaErrorPenalty)
+	}
+
+	sample := int64(elapsed)
+
+	for {
+		old := self.latency.Load()
+
+		value := sample
+		if old != 0 {
+			value = (old*4 + sample) / 5
+		}
+
+		if self.latency.CompareAndSwap(old, value) {
+			return
+		}
+	}
+}
+
+// openReplicas opens a connection pool for each of dsns. Connections are established lazily.
+func openReplicas(dsns []string) ([]*replica, error) {
+	var opened []*replica
+
+	for _, dsn := range dsns {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			closeReplicas(opened)
+			return nil, err
+		}
+
+		opened = append(opened, &replica{DB: db, dsn: dsn})
+	}
+
+	return opened, nil
+}
+
+// closeReplicas closes the connection pools of replicas.
+func closeReplicas(replicas []*replica) {
+	for _, replica := range replicas {
+		replica.Close()
+	}
+}
+
+// Every replicaProbeInterval-th read under LeastLatency goes to each replica in turn instead, so that
+// the latency of a replica that has been avoided is still measured, and it's used again once it
+// recovers.
+const replicaProbeInterval = 20
+
+// chooseReplica returns the replica to send the next read to, or nil if there are no replicas.
+func chooseReplica() *replica {
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	n := nextReplica.Add(1) - 1
+
+	if replicaSelection == LeastLatency {
+		if n%replicaProbeInterval != 0 {
+			chosen := replicas[0]
+			for _, replica := range replicas[1:] {
+				if replica.latency.Load() < chosen.latency.Load() {
+					chosen = replica
+				}
+			}
+			return chosen
+		}
+
+		n /= replicaProbeInterval
+	}
+
+	return replicas[n%uint64(len(replicas))]
+}
+
+// routeRead sets query to run on a replica, unless it's running within a transaction, has been
+// forced onto the primary, or there are no replicas.
+func routeRead(query *gorm.DB, onPrimary bool) *gorm.DB {
+	if onPrimary || inTransaction(query) {
+		return query
+	}
+
+	if replica := chooseReplica(); replica != nil {
+		query.Statement.ConnPool = replica
+	}
+
+	return query
+}
+
+var (
+	readPattern    = regexp.MustCompile(`(?i)^\s*(SELECT|WITH)\b`)
+	lockingPattern = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b`)
+)
+
+// isRead returns whether sql is a query that only reads, and can be sent to a replica.
+func isRead(sql string) bool {
+	return readPattern.MatchString(sql) && !lockingPattern.MatchString(sql)
+}
+
+// OnPrimary ensures that reads from this builder go to the primary rather than a replica, for when
+// they need to see the results of a recent write. This method does not modify the current builder.
+//
+// Example:
+//
+//	db.For[User](id).Update("name", "John")
+//	user, _ := db.For[User](id).OnPrimary().First()
+func (self *Builder[T]) OnPrimary() *Builder[T] {
+	builder := self.with(self.clone())
+	builder.onPrimary = true
+	return builder
+}
+
+// read returns a copy of the underlying query (see clone) that is routed to a replica if
+// appropriate.
+func (self *Builder[T]) read() *gorm.DB {
+	return routeRead(self.clone(), self.onPrimary)
+}
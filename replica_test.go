@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRead(t *testing.T) {
+	testCases := []struct {
+		sql      string
+		expected bool
+	}{
+		{sql: "SELECT * FROM users", expected: true},
+		{sql: "  select count(*) from users", expected: true},
+		{sql: "WITH t AS (SELECT 1) SELECT * FROM t", expected: true},
+		{sql: "SELECT * FROM users FOR UPDATE", expected: false},
+		{sql: "SELECT * FROM users LOCK IN SHARE MODE", expected: false},
+		{sql: "UPDATE users SET name = 'John'", expected: false},
+		{sql: "SELECTED", expected: false},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("Case%d", i+1), func(t *testing.T) {
+			assert.Equal(t, testCase.expected, isRead(testCase.sql))
+		})
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	dryRun(t)
+
+	opened, err := openReplicas([]string{"user@tcp(replica1)/test", "user@tcp(replica2)/test"})
+	assert.Nil(t, err)
+
+	replicas = opened
+	defer func() {
+		closeReplicas(replicas)
+		replicas = nil
+		replicaSelection = RoundRobin
+	}()
+
+	first := B[builderModel]().read().Statement.ConnPool
+	second := B[builderModel]().read().Statement.ConnPool
+	assert.ElementsMatch(t, []any{opened[0], opened[1]}, []any{first, second})
+
+	assert.Same(t, i.Statement.ConnPool, B[builderModel]().OnPrimary().read().Statement.ConnPool)
+
+	replicaSelection = LeastLatency
+	nextReplica.Store(1)
+	opened[0].latency.Store(200)
+	opened[1].latency.Store(100)
+	assert.Same(t, opened[1], B[builderModel]().read().Statement.ConnPool)
+}
+
+func TestLeastLatencyRecovery(t *testing.T) {
+	replicas = []*replica{{}, {}}
+	replicaSelection = LeastLatency
+	nextReplica.Store(0)
+	defer func() {
+		replicas = nil
+		replicaSelection = RoundRobin
+	}()
+
+	replicas[0].observe(0, driver.ErrBadConn)
+	replicas[1].observe(100*time.Millisecond, nil)
+
+	// The first replica is unreachable, so it's only chosen to probe whether it has recovered. Once
+	// it responds quickly again it's preferred.
+	probes := 0
+	for n := 0; n < 10*replicaProbeInterval; n++ {
+		if replica := chooseReplica(); replica == replicas[0] {
+			replica.observe(time.Millisecond, nil)
+			probes++
+		}
+	}
+
+	assert.Greater(t, probes, 1)
+	assert.Same(t, replicas[0], chooseReplica())
+}
+
+func TestObserve(t *testing.T) {
+	replica := &replica{}
+
+	replica.observe(100*time.Millisecond, nil)
+	assert.Equal(t, int64(100*time.Millisecond), replica.latency.Load())
+
+	// Errors that don't involve the replica being unreachable aren't recorded.
+	replica.observe(time.Millisecond, &mysql.MySQLError{Number: 1146, Message: "Table 'test.users' doesn't exist"})
+	assert.Equal(t, int64(100*time.Millisecond), replica.latency.Load())
+
+	replica.observe(time.Millisecond, driver.ErrBadConn)
+	assert.Equal(t, int64((4*100*time.Millisecond+replicaErrorPenalty)/5), replica.latency.Load())
+}
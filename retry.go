@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	retryPolicy *RetryPolicy
+	retries     atomic.Int64
+)
+
+// RetryPolicy describes how to retry operations that fail with a transient error, such as a
+// deadlock. Only operations that are safe to repeat are retried: the read finishers of Builder
+// (Find, First, Last, Count, Exists and FindByIDs, and those built on them) and the closures passed
+// to Transaction. Queries run within a transaction are never retried individually, as the
+// transaction has already been rolled back by then; retry the whole transaction instead.
+type RetryPolicy struct {
+	MaxAttempts int                          // The maximum number of attempts (including the first).
+	Backoff     time.Duration                // The delay before the first retry, which doubles for each retry.
+	MaxBackoff  time.Duration                // The maximum delay between attempts (0 for no maximum).
+	Errors      []error                      // The classes of error to retry (default: ErrDeadlock, ErrLockTimeout and ErrConnection).
+	OnRetry     func(attempt int, err error) // A function to call before each retry, e.g. to record metrics.
+}
+
+// SetRetryPolicy sets the policy for retrying operations that fail with a transient error. Set to
+// nil to disable retries.
+func SetRetryPolicy(policy *RetryPolicy) {
+	retryPolicy = policy
+}
+
+// Retries returns the number of times an operation has been retried since the program started.
+func Retries() int64 {
+	return retries.Load()
+}
+
+// retryable returns whether err belongs to one of the classes of error that the policy retries.
+func (self *RetryPolicy) retryable(err error) bool {
+	classes := self.Errors
+	if len(classes) == 0 {
+		classes = []error{ErrDeadlock, ErrLockTimeout, ErrConnection}
+	}
+
+	err = translateError(err)
+	for _, class := range classes {
+		if errors.Is(err, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the specified retry (starting at 1), with jitter so that
+// competing clients don't retry in lockstep.
+func (self *RetryPolicy) delay(retry int) time.Duration {
+	backoff := self.Backoff
+	for n := 1; n < retry && (self.MaxBackoff == 0 || backoff < self.MaxBackoff); n++ {
+		backoff *= 2
+	}
+
+	if self.MaxBackoff > 0 {
+		backoff = min(backoff, self.MaxBackoff)
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	// Wait for between half and all of the backoff.
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// retry runs f, running it again according to the retry policy if it fails with a transient error.
+// db is used for logging, to check that f isn't being run within a transaction, and for the context
+// that cancels waiting between attempts.
+func retry(db *gorm.DB, f func() error) error {
+	policy := retryPolicy
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || policy == nil || attempt >= policy.MaxAttempts || inTransaction(db) || !policy.retryable(err) {
+			return err
+		}
+
+		retries.Add(1)
+
+		delay := policy.delay(attempt)
+		db.Logger.Warn(db.Statement.Context, "retrying in %s after error (attempt %d of %d): %s", delay, attempt+1, policy.MaxAttempts, err)
+
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err)
+		}
+
+		if err := sleep(db.Statement.Context, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d, or until ctx is done, in which case it returns ctx's error.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRetry(t *testing.T) {
+	dryRun(t)
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	var retried []int
+	SetRetryPolicy(&RetryPolicy{
+		MaxAttempts: 3,
+		OnRetry:     func(attempt int, err error) { retried = append(retried, attempt) },
+	})
+	defer SetRetryPolicy(nil)
+
+	attempts := 0
+	err := retry(i, func() error {
+		attempts++
+		return deadlock
+	})
+	assert.Same(t, deadlock, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []int{1, 2}, retried)
+
+	attempts = 0
+	err = retry(i, func() error {
+		attempts++
+		if attempts == 1 {
+			return deadlock
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
+	attempts = 0
+	other := errors.New("other")
+	assert.Same(t, other, retry(i, func() error {
+		attempts++
+		return other
+	}))
+	assert.Equal(t, 1, attempts)
+
+	// Waiting between attempts stops when the context is cancelled.
+	SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, Backoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	attempts = 0
+	assert.ErrorIs(t, retry(i.WithContext(ctx), func() error {
+		attempts++
+		return deadlock
+	}), context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	testCases := []struct {
+		retry   int
+		maximum time.Duration
+	}{
+		{retry: 1, maximum: 100 * time.Millisecond},
+		{retry: 2, maximum: 200 * time.Millisecond},
+		{retry: 3, maximum: 300 * time.Millisecond},
+		{retry: 10, maximum: 300 * time.Millisecond},
+	}
+
+	for _, testCase := range testCases {
+		delay := policy.delay(testCase.retry)
+		assert.GreaterOrEqual(t, delay, testCase.maximum/2)
+		assert.LessOrEqual(t, delay, testCase.maximum)
+	}
+}
+
+// fakeBeginner is a connection pool that begins fake transactions (see fakeTx), for running
+// transactions in dry run mode.
+type fakeBeginner struct {
+	gorm.ConnPool
+}
+
+func (fakeBeginner) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	// gorm checks whether the transaction is nil, which needs a pointer.
+	return &fakeTx{}, nil
+}
+
+func TestTransactionRetry(t *testing.T) {
+	dryRun(t)
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	SetRetryPolicy(&RetryPolicy{MaxAttempts: 3})
+	defer SetRetryPolicy(nil)
+
+	db := i.Session(&gorm.Session{NewDB: true})
+	db.Statement.ConnPool = fakeBeginner{db.Statement.ConnPool}
+
+	// The error handler panics with the error, which is retried until it succeeds.
+	attempts := 0
+	assert.Nil(t, transaction(db, func(tx *gorm.DB) error {
+		if attempts++; attempts < 3 {
+			panic(deadlock)
+		}
+		return nil
+	}))
+	assert.Equal(t, 3, attempts)
+
+	// If the last attempt panics then so does the transaction.
+	attempts = 0
+	assert.PanicsWithValue(t, deadlock, func() {
+		transaction(db, func(tx *gorm.DB) error {
+			attempts++
+			panic(deadlock)
+		})
+	})
+	assert.Equal(t, 3, attempts)
+
+	// Returned errors are still returned.
+	attempts = 0
+	err := transaction(db, func(tx *gorm.DB) error {
+		attempts++
+		return deadlock
+	})
+	assert.ErrorIs(t, err, ErrDeadlock)
+	assert.Equal(t, 3, attempts)
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The statement setting that holds the names of the default scopes removed with WithoutScopes.
+const withoutScopesKey = "db:without_scopes"
+
+type defaultScope struct {
+	name  string
+	apply func(*gorm.DB) *gorm.DB
+}
+
+// defaultScopes maps model types to the default scopes registered for them.
+var defaultScopes = map[reflect.Type][]defaultScope{}
+
+// DefaultScope registers a scope named name that applies to every builder for model T, unless it is
+// removed with WithoutScopes. Default scopes are applied when the query runs, after any conditions
+// added to the builder. This should be called during initialisation, before any queries run.
+//
+// Example:
+//
+//	db.DefaultScope("visible", func(b *db.Builder[Post]) *db.Builder[Post] {
+//		return b.Where("hidden = ?", false)
+//	})
+func DefaultScope[T any](name string, scope func(*Builder[T]) *Builder[T]) {
+	t := reflect.TypeOf(new(T)).Elem()
+
+	defaultScopes[t] = append(defaultScopes[t], defaultScope{
+		name: name,
+		apply: func(tx *gorm.DB) *gorm.DB {
+			return scope(&Builder[T]{query: tx}).query
+		},
+	})
+}
+
+// applyDefaultScopes adds the default scopes registered for T to query.
+func applyDefaultScopes[T any](query *gorm.DB) *gorm.DB {
+	scopes := defaultScopes[reflect.TypeOf(new(T)).Elem()]
+	if len(scopes) == 0 {
+		return query
+	}
+
+	return query.Scopes(func(tx *gorm.DB) *gorm.DB {
+		var without map[string]bool
+		if value, ok := tx.Get(withoutScopesKey); ok {
+			without = value.(map[string]bool)
+		}
+
+		if without["*"] {
+			return tx
+		}
+
+		groupWhere(tx.Statement)
+
+		for _, scope := range scopes {
+			if !without[scope.name] {
+				tx = scope.apply(tx)
+			}
+		}
+
+		return tx
+	})
+}
+
+// groupWhere wraps the conditions of stmt's WHERE clause in parentheses if any of them are ORed,
+// so that conditions added afterwards apply to all of them. This is what gorm does before adding
+// its soft delete condition.
+func groupWhere(stmt *gorm.Statement) {
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return
+	}
+
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return
+	}
+
+	for _, expr := range where.Exprs {
+		if _, ok := expr.(clause.OrConditions); ok {
+			where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
+			c.Expression = where
+			stmt.Clauses["WHERE"] = c
+			return
+		}
+	}
+}
+
+// Scopes applies each of scopes to the builder, allowing common conditions to be reused.
+//
+// Example:
+//
+//	func Active(b *db.Builder[User]) *db.Builder[User] {
+//		return b.Where("active = ?", true)
+//	}
+//
+//	db.B[User]().Scopes(Active).Find()
+func (self *Builder[T]) Scopes(scopes ...func(*Builder[T]) *Builder[T]) *Builder[T] {
+	builder := self
+	for _, scope := range scopes {
+		builder = scope(builder)
+	}
+	return builder
+}
+
+// WithoutScopes ensures queries don't include the default scopes named names (see DefaultScope), or
+// any default scopes if no names are passed. This method does not modify the current builder.
+func (self *Builder[T]) WithoutScopes(names ...string) *Builder[T] {
+	without := map[string]bool{}
+	if value, ok := self.query.Get(withoutScopesKey); ok {
+		for name := range value.(map[string]bool) {
+			without[name] = true
+		}
+	}
+
+	if len(names) == 0 {
+		without["*"] = true
+	}
+
+	for _, name := range names {
+		without[name] = true
+	}
+
+	return self.with(self.clone().Set(withoutScopesKey, without))
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// cascades maps model types to functions that soft-delete the children of rows with the specified
+// primary keys.
+var cascades = map[reflect.Type][]func(tx *gorm.DB, ids []any) error{}
+
+// Cascade declares that when rows of Parent are soft-deleted with Builder.Delete, rows of Child
+// whose foreignKey column refers to them are soft-deleted too, within the same transaction.
+// Cascades are followed recursively, so children can have cascades of their own. This should be
+// called during initialisation, before any queries run.
+//
+// Because Model implementations normally delete themselves with db.For[T](self.ID).Delete(),
+// declaring a cascade also covers a model's Delete method. Cascade panics if Child doesn't support
+// soft deletes (it has no gorm.DeletedAt field), as its rows would be deleted permanently.
+//
+// Example:
+//
+//	db.Cascade[User, Post]("user_id")
+func Cascade[Parent any, Child any](foreignKey string) {
+	if softDeleteField(must(schema.Parse(new(Child), &sync.Map{}, schema.NamingStrategy{}))) == nil {
+		panic("child model does not support soft deletes")
+	}
+
+	t := reflect.TypeOf(new(Parent)).Elem()
+
+	cascades[t] = append(cascades[t], func(tx *gorm.DB, ids []any) error {
+		query := tx.Session(&gorm.Session{NewDB: true}).
+			Model(new(Child)).
+			Where(clause.IN{Column: clause.Column{Name: foreignKey}, Values: ids})
+
+		_, err := cascadeDelete[Child](query)
+		return err
+	})
+}
+
+// softDelete soft-deletes the rows of T that match query, along with any cascades declared for T,
+// and returns the number of rows of T that were affected.
+func softDelete[T any](query *gorm.DB) (int64, error) {
+	if len(cascades[reflect.TypeOf(new(T)).Elem()]) == 0 {
+		res := query.Delete(new(T))
+		return res.RowsAffected, res.Error
+	}
+
+	var n int64
+	err := query.Transaction(func(tx *gorm.DB) (err error) {
+		n, err = cascadeDelete[T](tx)
+		return err
+	})
+
+	return n, err
+}
+
+// cascadeDelete soft-deletes the rows of T that match query after soft-deleting their children. It
+// must be called within a transaction.
+func cascadeDelete[T any](query *gorm.DB) (int64, error) {
+	if fs := cascades[reflect.TypeOf(new(T)).Elem()]; len(fs) > 0 {
+		ids, err := primaryKeys[T](query)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(ids) > 0 {
+			for _, f := range fs {
+				if err := f(query, ids); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	res := query.Delete(new(T))
+	return res.RowsAffected, res.Error
+}
+
+// primaryKeys returns the primary keys of the rows of T that match query.
+func primaryKeys[T any](query *gorm.DB) ([]any, error) {
+	if err := query.Statement.Parse(new(T)); err != nil {
+		return nil, err
+	}
+
+	field := query.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		panic("model has no primary key")
+	}
+
+	ids := reflect.New(reflect.SliceOf(field.FieldType))
+	if err := query.Session(&gorm.Session{}).Pluck(field.DBName, ids.Interface()).Error; err != nil {
+		return nil, err
+	}
+
+	return toSlice(ids.Elem().Interface()), nil
+}
+
+// OnlyTrashed ensures queries only include soft-deleted rows. Delete panics on the resulting
+// builder, as the rows are already soft-deleted; use HardDelete to delete them permanently. This
+// method does not modify the current builder.
+func (self *Builder[T]) OnlyTrashed() *Builder[T] {
+	builder := self.with(whereTrashed(self.clone(), self.deletedAt()))
+	builder.onlyTrashed = true
+	return builder
+}
+
+// Restore restores all soft-deleted rows that match the query, and returns the number of rows
+// restored.
+func (self *Builder[T]) Restore() Result {
+	field := self.deletedAt()
+
+	res := whereTrashed(self.clone(), field).Updates(map[string]any{field.DBName: nil})
+	must0(res.Error)
+	self.checkAffected(res.RowsAffected > 0)
+
+	return Result{RowsAffected: res.RowsAffected}
+}
+
+// deletedAt returns the soft delete field of T.
+func (self *Builder[T]) deletedAt() *schema.Field {
+	must0(self.query.Statement.Parse(new(T)))
+
+	if field := softDeleteField(self.query.Statement.Schema); field != nil {
+		return field
+	}
+
+	panic("model does not support soft deletes")
+}
+
+// softDeleteField returns the soft delete field of s, or nil if it doesn't have one.
+func softDeleteField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			return field
+		}
+	}
+	return nil
+}
+
+// whereTrashed returns query restricted to rows that have been soft-deleted, according to field.
+func whereTrashed(query *gorm.DB, field *schema.Field) *gorm.DB {
+	query = query.Unscoped()
+
+	// Group any ORed conditions so that the condition applies to all of them, as gorm does for its
+	// own soft delete condition.
+	groupWhere(query.Statement)
+
+	return query.Where(isTrashed(field))
+}
+
+// isTrashed returns a condition that matches rows that have been soft-deleted.
+func isTrashed(field *schema.Field) clause.Expression {
+	return clause.Expr{
+		SQL:  "? IS NOT NULL",
+		Vars: []any{clause.Column{Table: clause.CurrentTable, Name: field.DBName}},
+	}
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type sdParent struct {
+	ID        uint
+	DeletedAt gorm.DeletedAt
+}
+
+type sdChild struct {
+	ID         uint
+	SdParentID uint
+	DeletedAt  gorm.DeletedAt
+}
+
+type sdGrandchild struct {
+	ID        uint
+	SdChildID uint
+	DeletedAt gorm.DeletedAt
+}
+
+func TestOnlyTrashed(t *testing.T) {
+	dryRun(t)
+
+	assert.Equal(t, "SELECT * FROM `sd_parents` WHERE id = 1 AND `sd_parents`.`deleted_at` IS NOT NULL", findSQL(B[sdParent]("id = ?", 1).OnlyTrashed()))
+
+	var statements []string
+	assert.Nil(t, i.Callback().Delete().After("*").Register("test:record", func(db *gorm.DB) {
+		statements = append(statements, db.Statement.SQL.String())
+	}))
+
+	B[sdParent]("id = ?", 1).OnlyTrashed().HardDelete()
+	B[sdParent]("id = ?", 1).OrWhere("id = ?", 2).OnlyTrashed().HardDelete()
+	assert.Equal(t, []string{
+		"DELETE FROM `sd_parents` WHERE id = ? AND `sd_parents`.`deleted_at` IS NOT NULL",
+		"DELETE FROM `sd_parents` WHERE (id = ? OR id = ?) AND `sd_parents`.`deleted_at` IS NOT NULL",
+	}, statements)
+
+	assert.Panics(t, func() { B[sdParent]("id = ?", 1).OnlyTrashed().Delete() })
+}
+
+func TestRestore(t *testing.T) {
+	dryRun(t)
+
+	var statements []string
+	assert.Nil(t, i.Callback().Update().After("*").Register("test:record", func(db *gorm.DB) {
+		statements = append(statements, i.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	}))
+
+	B[sdParent]("id = ?", 1).Restore()
+	B[sdParent]("id = ?", 1).OrWhere("id = ?", 2).Restore()
+	assert.Equal(t, []string{
+		"UPDATE `sd_parents` SET `deleted_at`=NULL WHERE id = 1 AND `sd_parents`.`deleted_at` IS NOT NULL",
+		"UPDATE `sd_parents` SET `deleted_at`=NULL WHERE (id = 1 OR id = 2) AND `sd_parents`.`deleted_at` IS NOT NULL",
+	}, statements)
+}
+
+func TestCascade(t *testing.T) {
+	dryRun(t)
+
+	Cascade[sdParent, sdChild]("sd_parent_id")
+	Cascade[sdChild, sdGrandchild]("sd_child_id")
+
+	// Rows of a model without soft deletes would be deleted permanently.
+	assert.Panics(t, func() { Cascade[sdParent, builderModel]("sd_parent_id") })
+
+	var statements []string
+	record := func(db *gorm.DB) {
+		statements = append(statements, db.Statement.SQL.String())
+	}
+
+	// Nothing is found in dry run mode, so pretend each query for primary keys finds two rows.
+	findIDs := func(db *gorm.DB) {
+		if ids, ok := db.Statement.Dest.(*[]uint); ok {
+			*ids = []uint{1, 2}
+		}
+	}
+
+	callbacks := i.Callback()
+	assert.Nil(t, callbacks.Query().After("gorm:query").Register("test:find_ids", findIDs))
+	assert.Nil(t, callbacks.Query().After("*").Register("test:record", record))
+	assert.Nil(t, callbacks.Delete().After("*").Register("test:record", record))
+	assert.Nil(t, callbacks.Raw().After("*").Register("test:record", record))
+
+	// Transactions can't be started in dry run mode, so the cascade runs within a savepoint of a fake
+	// transaction instead.
+	tx := &gorm.DB{Statement: &gorm.Statement{ConnPool: fakeTx{}}}
+	B[sdParent]("id = ?", 1).Tx(tx).Delete()
+
+	assert.Regexp(t, "^SAVEPOINT ", statements[0])
+	assert.Equal(t, []string{
+		"SELECT `id` FROM `sd_parents` WHERE id = ? AND `sd_parents`.`deleted_at` IS NULL",
+		"SELECT `id` FROM `sd_children` WHERE `sd_parent_id` IN (?,?) AND `sd_children`.`deleted_at` IS NULL",
+		"UPDATE `sd_grandchildren` SET `deleted_at`=? WHERE `sd_child_id` IN (?,?) AND `sd_grandchildren`.`deleted_at` IS NULL",
+		"UPDATE `sd_children` SET `deleted_at`=? WHERE `sd_parent_id` IN (?,?) AND `sd_children`.`deleted_at` IS NULL",
+		"UPDATE `sd_parents` SET `deleted_at`=? WHERE id = ? AND `sd_parents`.`deleted_at` IS NULL",
+	}, statements[1:])
+}
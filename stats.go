@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	queries         atomic.Int64
+	queryErrors     atomic.Int64
+	stopPoolMonitor func()
+)
+
+// Statistics holds statistics about the connection pools and the queries that have been run.
+type Statistics struct {
+	Primary  sql.DBStats   // The statistics of the primary's connection pool.
+	Replicas []sql.DBStats // The statistics of each replica's connection pool, in the order configured.
+	Queries  int64         // The number of statements that have been run.
+	Errors   int64         // The number of statements that have failed (not including record not found).
+	Retries  int64         // The number of times an operation has been retried (see RetryPolicy).
+}
+
+// Stats returns statistics about the connection pools and the queries that have been run since the
+// program started.
+func Stats() Statistics {
+	stats := Statistics{
+		Queries: queries.Load(),
+		Errors:  queryErrors.Load(),
+		Retries: retries.Load(),
+	}
+
+	if db, err := i.DB(); err == nil {
+		stats.Primary = db.Stats()
+	}
+
+	for _, replica := range replicas {
+		stats.Replicas = append(stats.Replicas, replica.Stats())
+	}
+
+	return stats
+}
+
+// countQuery increments the query counters for db's statement.
+func countQuery(db *gorm.DB) {
+	if db.DryRun {
+		return
+	}
+
+	queries.Add(1)
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		queryErrors.Add(1)
+	}
+}
+
+// configurePool applies the connection pool settings of config to db.
+func configurePool(db *sql.DB, config *Config) {
+	if config.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+
+	if config.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	if config.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+
+	if config.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+}
+
+// The interval at which the pool monitor checks how long queries have been waiting for connections.
+const poolMonitorInterval = 10 * time.Second
+
+// startPoolMonitor starts checking the primary's connection pool in the background, logging a
+// warning whenever the average time spent waiting for a connection over an interval exceeds
+// threshold. Any existing monitor is stopped.
+func startPoolMonitor(db *gorm.DB, threshold time.Duration) {
+	if stopPoolMonitor != nil {
+		stopPoolMonitor()
+		stopPoolMonitor = nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil || threshold <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(poolMonitorInterval)
+	done := make(chan struct{})
+
+	stopPoolMonitor = func() {
+		ticker.Stop()
+		close(done)
+	}
+
+	go func() {
+		last := sqlDB.Stats()
+
+		for {
+			select {
+			case <-done:
+				return
+
+			case <-ticker.C:
+				stats := sqlDB.Stats()
+				if wait := averageWait(last, stats); wait > threshold {
+					db.Logger.Warn(context.Background(),
+						"connection pool wait time is high: %s on average for %d queries (%d/%d connections in use)",
+						wait.Round(time.Millisecond), stats.WaitCount-last.WaitCount, stats.InUse, stats.MaxOpenConnections,
+					)
+				}
+				last = stats
+			}
+		}
+	}()
+}
+
+// averageWait returns the average time spent waiting for a connection between two snapshots of a
+// pool's statistics.
+func averageWait(before sql.DBStats, after sql.DBStats) time.Duration {
+	count := after.WaitCount - before.WaitCount
+	if count <= 0 {
+		return 0
+	}
+	return (after.WaitDuration - before.WaitDuration) / time.Duration(count)
+}
@@ -0,0 +1,16 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAverageWait(t *testing.T) {
+	before := sql.DBStats{WaitCount: 10, WaitDuration: time.Second}
+
+	assert.Equal(t, time.Duration(0), averageWait(before, before))
+	assert.Equal(t, 250*time.Millisecond, averageWait(before, sql.DBStats{WaitCount: 14, WaitDuration: 2 * time.Second}))
+}
@@ -0,0 +1,70 @@
+package db
+
+import "gorm.io/gorm"
+
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+// Transaction
+// —————————————————————————————————————————————————————————————————————————————————————————————————
+
+func transaction(i *gorm.DB, f func(tx *gorm.DB) error) error {
+	if closing.Load() && !inTransaction(i) {
+		return ErrClosed
+	}
+
+	// The error handler may have panicked with a transient error from within f, in which case the
+	// transaction has been rolled back and can be retried. If it still fails on the last attempt then
+	// the panic is resumed.
+	var panicked any
+
+	err := retry(i, func() (err error) {
+		panicked = nil
+
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(error); ok && retryPolicy != nil && retryPolicy.retryable(e) {
+					panicked = r
+					err = e
+					return
+				}
+				panic(r)
+			}
+		}()
+
+		return i.Transaction(f)
+	})
+
+	if panicked != nil {
+		panic(panicked)
+	}
+
+	return translateError(err)
+}
+
+// Transaction runs f within a transaction, which is committed if f returns nil, and rolled back if
+// f returns an error or panics. Use Builder.Tx to run the queries of a builder within tx.
+//
+// If a retry policy is set and f fails with a transient error (such as a deadlock) then the whole
+// transaction is retried, so f should have no side effects outside of the database.
+//
+// Example:
+//
+//	err := db.Transaction(func(tx *gorm.DB) error {
+//		row, _ := db.For[Model](id).Tx(tx).LockForUpdate().First()
+//		db.For[Model](id).Tx(tx).Update("n", row.N-1)
+//		return nil
+//	})
+func Transaction(f func(tx *gorm.DB) error) error {
+	return transaction(i, f)
+}
+
+// TransactionD runs f within a transaction (in debug mode), which is committed if f returns nil, and
+// rolled back if f returns an error or panics.
+func TransactionD(f func(tx *gorm.DB) error) error {
+	return transaction(i.Debug(), f)
+}
+
+// inTransaction returns whether db is running within a transaction.
+func inTransaction(db *gorm.DB) bool {
+	committer, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok && committer != nil
+}
@@ -1,5 +1,13 @@
 package db
 
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
 // chain runs the provided functions until it reaches one that returns a non-nil error, then returns
 // it. Returns nil if none of the functions errored.
 func chain(fs ...func() error) error {
@@ -11,3 +19,89 @@ func chain(fs ...func() error) error {
 
 	return nil
 }
+
+// lookUpFields returns the fields of s with the specified names, which may be either field names or
+// column names.
+func lookUpFields(s *schema.Schema, names []string) ([]*schema.Field, error) {
+	fields := make([]*schema.Field, 0, len(names))
+
+	for _, name := range names {
+		field := s.LookUpField(name)
+		if field == nil {
+			return nil, fmt.Errorf("unknown column: %q", name)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// toSlice returns the elements of values if it is a slice or array, or values on its own otherwise.
+func toSlice(values any) []any {
+	if s, ok := values.([]any); ok {
+		return s
+	}
+
+	v := reflect.ValueOf(values)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return []any{values}
+	}
+
+	s := make([]any, v.Len())
+	for n := range s {
+		s[n] = v.Index(n).Interface()
+	}
+
+	return s
+}
+
+// rowIndex looks up rows by the values of their keys. Values are compared as strings so that
+// integers of different types match, and strings that don't match exactly are compared ignoring
+// case and trailing spaces, as the database's collation probably does.
+type rowIndex[T any] struct {
+	exact  map[string]*T
+	folded map[string]*T
+}
+
+// newRowIndex returns a rowIndex of rows, using keysOf to get the values of the keys of each row.
+func newRowIndex[T any](rows []*T, keysOf func(row *T) []any) *rowIndex[T] {
+	index := &rowIndex[T]{
+		exact:  make(map[string]*T, len(rows)),
+		folded: make(map[string]*T, len(rows)),
+	}
+
+	for _, row := range rows {
+		keys := keysOf(row)
+		index.exact[indexKey(keys, false)] = row
+		index.folded[indexKey(keys, true)] = row
+	}
+
+	return index
+}
+
+// find returns the row with the specified values of its keys, if there is one.
+func (self *rowIndex[T]) find(keys ...any) (*T, bool) {
+	if row, ok := self.exact[indexKey(keys, false)]; ok {
+		return row, true
+	}
+
+	row, ok := self.folded[indexKey(keys, true)]
+	return row, ok
+}
+
+// indexKey returns keys encoded as a string. If fold is true then strings are lowercased and have
+// trailing spaces removed.
+func indexKey(keys []any, fold bool) string {
+	var b strings.Builder
+
+	for _, key := range keys {
+		s := fmt.Sprint(key)
+		if key, ok := key.(string); ok && fold {
+			s = strings.ToLower(strings.TrimRight(key, " "))
+		}
+		// Quoting keeps the values of composite keys from running into each other.
+		fmt.Fprintf(&b, "%q", s)
+	}
+
+	return b.String()
+}